@@ -0,0 +1,143 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+//Bind command which modify fetcher by struct tags of given struct or struct pointer.
+//Tag format is "name,omitempty",fields without bind tags will be skipped.
+//Tag path:"id" replaces "{id}" placeholder in url path with escaped field value,
+//and sets template var "id" if fetcher url template is set.
+//Tag query:"page" sets url query,slice field will be added as multiple values.
+//Tag header:"X-Tenant" sets header,slice field will be added as multiple values.
+//Tag body:"json" sets body by JSONBody,"xml" and "form" are also supported by XMLBody and FormStructBody.
+//Field values are formatted in same way as EncodeForm,including "layout" tag for time.Time.
+//Embedded structs without bind tags will be flattened.
+func Bind(v interface{}) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return nil
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return fmt.Errorf("fetcher:bind requires struct,got %s", rv.Kind())
+		}
+		if !rv.CanAddr() {
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			rv = cp
+		}
+		return bindStruct(f, rv)
+	})
+}
+
+var bindTags = []string{"path", "query", "header", "body"}
+
+func bindStruct(f *Fetcher, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		tagged := false
+		for _, tagname := range bindTags {
+			tag, ok := field.Tag.Lookup(tagname)
+			if !ok || tag == "-" {
+				continue
+			}
+			tagged = true
+			if field.PkgPath != "" {
+				continue
+			}
+			err := bindField(f, tagname, tag, field, fv)
+			if err != nil {
+				return fmt.Errorf("fetcher:field %s:%w", field.Name, err)
+			}
+		}
+		if tagged || !field.Anonymous {
+			continue
+		}
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			err := bindStruct(f, fv)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func bindField(f *Fetcher, tagname string, tag string, field reflect.StructField, fv reflect.Value) error {
+	name, omitempty := parseTag(tag)
+	if name == "" {
+		return fmt.Errorf("fetcher:empty %s tag name", tagname)
+	}
+	if omitempty && fv.IsZero() {
+		return nil
+	}
+	if tagname == "body" {
+		return bindBody(f, name, fv)
+	}
+	strs, err := formatValues(fv, field.Tag.Get("layout"))
+	if err != nil {
+		return err
+	}
+	switch tagname {
+	case "path":
+		if len(strs) != 1 {
+			return fmt.Errorf("fetcher:path param %q requires exactly one value", name)
+		}
+		if f.URLTemplate != "" {
+			f.TemplateVars = mergeTemplateVars(f.TemplateVars, map[string]interface{}{name: strs[0]})
+		}
+		replacePathParam(f.URL, "{"+name+"}", strs[0])
+	case "query":
+		q := f.URL.Query()
+		q.Del(name)
+		for _, s := range strs {
+			q.Add(name, s)
+		}
+		f.URL.RawQuery = q.Encode()
+	case "header":
+		f.Header.Del(name)
+		for _, s := range strs {
+			f.Header.Add(name, s)
+		}
+	}
+	return nil
+}
+
+func bindBody(f *Fetcher, format string, fv reflect.Value) error {
+	v := fv.Interface()
+	switch format {
+	case "json":
+		return JSONBody(v).Exec(f)
+	case "xml":
+		return XMLBody(v).Exec(f)
+	case "form":
+		return FormStructBody(v).Exec(f)
+	}
+	return fmt.Errorf("fetcher:unsupported body format %q", format)
+}
+
+func replacePathParam(u *url.URL, placeholder string, value string) {
+	escaped := u.EscapedPath()
+	u.Path = strings.Replace(u.Path, placeholder, value, -1)
+	rawpath := strings.Replace(escaped, placeholder, url.PathEscape(value), -1)
+	rawpath = strings.Replace(rawpath, url.PathEscape(placeholder), url.PathEscape(value), -1)
+	u.RawPath = ""
+	if rawpath != u.EscapedPath() {
+		u.RawPath = rawpath
+	}
+}
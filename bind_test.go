@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testBindBase struct {
+	Tenant string `header:"X-Tenant"`
+}
+
+type testBindBody struct {
+	Name string `json:"name"`
+}
+
+type testBind struct {
+	testBindBase
+	ID      string        `path:"id"`
+	Version int           `path:"version"`
+	Page    int           `query:"page,omitempty"`
+	Tags    []string      `query:"tag"`
+	Since   time.Time     `query:"since" layout:"2006-01-02"`
+	Until   *time.Time    `query:"until,omitempty" layout:"unix"`
+	Level   testFormLevel `header:"X-Level"`
+	Accepts []string      `header:"Accept"`
+	Body    *testBindBody `body:"json"`
+	Ignored string        `query:"-"`
+	Plain   string
+}
+
+func TestBind(t *testing.T) {
+	until := time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)
+	v := &testBind{
+		testBindBase: testBindBase{Tenant: "tenant"},
+		ID:           "a/b c",
+		Version:      2,
+		Tags:         []string{"x", "y"},
+		Since:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Until:        &until,
+		Level:        1,
+		Accepts:      []string{"text/plain", "application/json"},
+		Body:         &testBindBody{Name: "body"},
+		Ignored:      "ignored",
+		Plain:        "plain",
+	}
+	f := New()
+	err := Exec(f, URL("http://127.0.0.1/users/{id}/v{version}?page=9&keep=1"), SetHeader("Accept", "*/*"), Bind(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.Path != "/users/a/b c/v2" || f.URL.EscapedPath() != "/users/a%2Fb%20c/v2" {
+		t.Fatal(f.URL.Path, f.URL.EscapedPath())
+	}
+	q := f.URL.Query()
+	if q.Get("page") != "9" || q.Get("keep") != "1" || len(q["tag"]) != 2 || q.Get("since") != "2020-01-02" || q.Get("until") != "1578009600" || q.Get("Ignored") != "" || q.Get("Plain") != "" {
+		t.Fatal(f.URL.RawQuery)
+	}
+	if f.Header.Get("X-Tenant") != "tenant" || f.Header.Get("X-Level") != "level1" || len(f.Header["Accept"]) != 2 || f.Header.Get("Accept") != "text/plain" {
+		t.Fatal(f.Header)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "http://127.0.0.1/users/a%2Fb%20c/v2?keep=1&page=9&since=2020-01-02&tag=x&tag=y&until=1578009600" {
+		t.Fatal(req.URL.String())
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil || string(data) != `{"name":"body"}` {
+		t.Fatal(string(data), err)
+	}
+	v.Page = 3
+	v.Until = nil
+	v.Body = nil
+	f = New()
+	err = Exec(f, URL("http://127.0.0.1/users/{id}/v{version}"), Bind(*v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.Query().Get("page") != "3" || f.URL.Query().Get("until") != "" {
+		t.Fatal(f.URL.RawQuery)
+	}
+	err = Bind((*testBind)(nil)).Exec(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	var cases = []interface{}{
+		"string",
+		struct {
+			ID *string `path:"id"`
+		}{},
+		struct {
+			Body string `body:"yaml"`
+		}{},
+		struct {
+			Query map[string]string `query:"q"`
+		}{Query: map[string]string{}},
+		struct {
+			Header string `header:""`
+		}{},
+	}
+	for _, v := range cases {
+		err := Bind(v).Exec(New())
+		if err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestBindBodyFormats(t *testing.T) {
+	f := New()
+	err := Bind(&struct {
+		Form struct {
+			Name string `form:"name"`
+		} `body:"form"`
+	}{}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Header.Get("Content-Type") != FormContentType || f.BodySource.Size() != int64(len("name=")) {
+		t.Fatal(f)
+	}
+	f = New()
+	err = Bind(&struct {
+		XML testBindBody `body:"xml"`
+	}{XML: testBindBody{Name: "xml"}}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Header.Get("Content-Type") != XMLContentType || f.BodySource == nil {
+		t.Fatal(f)
+	}
+	values, err := EncodeForm(struct {
+		Created time.Time `form:"created" layout:"unixmilli"`
+	}{Created: time.Unix(1, int64(time.Millisecond))})
+	if err != nil || values.Encode() != (url.Values{"created": []string{"1001"}}).Encode() {
+		t.Fatal(values, err)
+	}
+}
@@ -0,0 +1,129 @@
+package fetcher
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+//BodySource request body source which can be opened repeatedly.
+//Request created with body source can be replayed by redirects and retries,
+//and preset with body source can be shared between goroutines.
+type BodySource interface {
+	//Open open new reader of body.
+	//Return reader and any error if raised.
+	Open() (io.ReadCloser, error)
+	//Size return body size.
+	//Negative value means unknown size.
+	Size() int64
+}
+
+//BytesSource body source of byte slice.
+type BytesSource []byte
+
+//Open open new reader of body.
+//Return reader and any error if raised.
+func (s BytesSource) Open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(s)), nil
+}
+
+//Size return body size.
+func (s BytesSource) Size() int64 {
+	return int64(len(s))
+}
+
+//StringSource body source of string.
+type StringSource string
+
+//Open open new reader of body.
+//Return reader and any error if raised.
+func (s StringSource) Open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(string(s))), nil
+}
+
+//Size return body size.
+func (s StringSource) Size() int64 {
+	return int64(len(s))
+}
+
+//FileSource body source of file with given path.
+//File will be opened every time body read.
+type FileSource string
+
+//Open open file of body.
+//Return file and any error if raised.
+func (s FileSource) Open() (io.ReadCloser, error) {
+	return os.Open(string(s))
+}
+
+//Size return file size.
+//Return -1 if file can not be stated.
+func (s FileSource) Size() int64 {
+	info, err := os.Stat(string(s))
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+//FuncSource body source of factory func.
+type FuncSource struct {
+	//OpenFunc func which opens new reader of body.
+	OpenFunc func() (io.ReadCloser, error)
+	//BodySize body size.
+	//Zero or negative value means unknown size,so that zero value FuncSource will not send empty body.
+	BodySize int64
+}
+
+//Open open new reader of body.
+//Return reader and any error if raised.
+func (s *FuncSource) Open() (io.ReadCloser, error) {
+	return s.OpenFunc()
+}
+
+//Size return body size.
+//Return -1 if body size is unknown.
+func (s *FuncSource) Size() int64 {
+	if s.BodySize <= 0 {
+		return -1
+	}
+	return s.BodySize
+}
+
+//SetBodySource command which modify fetcher body source to given source.
+//Fetcher body will be set to nil.
+func SetBodySource(src BodySource) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		f.Body = nil
+		f.BodySource = src
+		return nil
+	})
+}
+
+//BytesBody command which modify fetcher body to given byte slice.
+//Body can be replayed.
+func BytesBody(data []byte) Command {
+	return SetBodySource(BytesSource(data))
+}
+
+//StringBody command which modify fetcher body to given string.
+//Body can be replayed.
+func StringBody(data string) Command {
+	return SetBodySource(StringSource(data))
+}
+
+//FileBody command which modify fetcher body to file with given path.
+//File will be opened when request created.
+//Body can be replayed.
+func FileBody(path string) Command {
+	return SetBodySource(FileSource(path))
+}
+
+//BodyFunc command which modify fetcher body to reader created by given func.
+//Func will be called every time body opened,size is unknown.
+//Body can be replayed.
+func BodyFunc(fn func() (io.ReadCloser, error)) Command {
+	return SetBodySource(&FuncSource{OpenFunc: fn, BodySize: -1})
+}
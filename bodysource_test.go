@@ -0,0 +1,212 @@
+package fetcher
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func readBodySource(t *testing.T, src BodySource) string {
+	rc, err := src.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBodySource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetcherbody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "body.txt")
+	err = ioutil.WriteFile(path, []byte("file"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases = map[string]BodySource{
+		"bytes":  BytesSource("bytes"),
+		"string": StringSource("string"),
+		"file":   FileSource(path),
+		"func": &FuncSource{OpenFunc: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader("func")), nil
+		}, BodySize: 4},
+	}
+	for expected, src := range cases {
+		for i := 0; i < 2; i++ {
+			if data := readBodySource(t, src); data != expected {
+				t.Fatal(data)
+			}
+		}
+		if src.Size() != int64(len(expected)) {
+			t.Fatal(expected, src.Size())
+		}
+	}
+	if FileSource(filepath.Join(dir, "notexist")).Size() != -1 {
+		t.Fatal()
+	}
+	if (&FuncSource{}).Size() != -1 {
+		t.Fatal()
+	}
+}
+
+func TestFetcherBodySource(t *testing.T) {
+	f := New()
+	err := StringBody("data").Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Method = "POST"
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != 4 || req.GetBody == nil {
+		t.Fatal(req)
+	}
+	for i := 0; i < 2; i++ {
+		body, err := req.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(body)
+		if err != nil || string(data) != "data" {
+			t.Fatal(string(data), err)
+		}
+	}
+	err = BytesBody(nil).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err = f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != 0 || req.Body != http.NoBody {
+		t.Fatal(req)
+	}
+	err = BodyFunc(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("func")), nil
+	}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err = f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != -1 || req.GetBody == nil {
+		t.Fatal(req)
+	}
+	err = SetBodySource(&FuncSource{OpenFunc: func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("func")), nil
+	}}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err = f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != -1 || req.Body == http.NoBody {
+		t.Fatal(req)
+	}
+	bs, err := ioutil.ReadAll(req.Body)
+	if err != nil || string(bs) != "func" {
+		t.Fatal(string(bs), err)
+	}
+	openErr := errors.New("open error")
+	err = BodyFunc(func() (io.ReadCloser, error) {
+		return nil, openErr
+	}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = f.Raw()
+	if err != openErr {
+		t.Fatal(err)
+	}
+	err = Body(strings.NewReader("reader")).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.BodySource != nil {
+		t.Fatal(f)
+	}
+	err = FileBody("file").Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Body != nil || f.BodySource != FileSource("file") {
+		t.Fatal(f)
+	}
+}
+
+func TestBodySourceReplay(t *testing.T) {
+	var locker sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/flaky", http.StatusTemporaryRedirect)
+			return
+		case "/flaky":
+			locker.Lock()
+			attempts++
+			n := attempts
+			locker.Unlock()
+			if n%2 == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Write(data)
+	}))
+	defer server.Close()
+	client := &Client{Retry: &RetryConfig{BackoffInMillisecond: 1, RetryNonIdempotent: true}}
+	preset := NewPreset().With(client, Post, URL(server.URL+"/redirect"), JSONBody("data"))
+	var result string
+	_, err := FetchAndParse(preset, Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != `"data"` {
+		t.Fatal(result)
+	}
+	preset = NewPreset().With(Post, URL(server.URL+"/echo"), StringBody("shared"))
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result string
+			_, err := FetchAndParse(preset, Should200(AsString(&result)))
+			if err == nil && result != "shared" {
+				err = errors.New(result)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
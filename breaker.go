@@ -0,0 +1,283 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//DefaultCircuitFailureThreshold default consecutive failures which open the circuit.
+var DefaultCircuitFailureThreshold = 5
+
+//DefaultCircuitCoolDown default duration circuit keeps open before half-open.
+var DefaultCircuitCoolDown = 30 * time.Second
+
+//DefaultCircuitHalfOpenRequests default trial requests allowed when circuit is half-open.
+var DefaultCircuitHalfOpenRequests = 1
+
+//CircuitState circuit state type
+type CircuitState int
+
+const (
+	//CircuitClosed circuit closed state.Requests will be done normally.
+	CircuitClosed = CircuitState(iota)
+	//CircuitOpen circuit open state.Requests will fail fast.
+	CircuitOpen
+	//CircuitHalfOpen circuit half-open state.Only trial requests will be done.
+	CircuitHalfOpen
+)
+
+//String return circuit state name.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+//CircuitOpenErr error returned when circuit is open.
+type CircuitOpenErr struct {
+	//Key circuit key
+	Key string
+	//Until time when circuit will be half-open.
+	Until time.Time
+}
+
+//Error return error message.
+func (e *CircuitOpenErr) Error() string {
+	return fmt.Sprintf("fetcher:circuit open [%s] until %s", e.Key, e.Until.Format(time.RFC3339))
+}
+
+//IsCircuitOpenErr check if error is a circuit open error.
+func IsCircuitOpenErr(err error) bool {
+	var e *CircuitOpenErr
+	return errors.As(err, &e)
+}
+
+//CircuitKeyByHost circuit key func which use request url host as key.
+func CircuitKeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+//IsCircuitFailure default circuit failure classifier.
+//Network errors except canceled requests and server errors(>=500) are failures.
+func IsCircuitFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= 500
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	trials   int
+	openedAt time.Time
+}
+
+//CircuitBreaker circuit breaker which tracks circuits by key.
+//Circuit breaker can be shared by multiple doers.
+type CircuitBreaker struct {
+	//FailureThreshold consecutive failures which open the circuit.
+	FailureThreshold int
+	//CoolDown duration circuit keeps open before half-open.
+	CoolDown time.Duration
+	//HalfOpenRequests trial requests allowed when circuit is half-open.
+	HalfOpenRequests int
+	//KeyFunc func which return circuit key of request.
+	//Request url host will be used if nil.
+	KeyFunc func(*http.Request) string
+	//IsFailure func which check if result is a failure.
+	//IsCircuitFailure will be used if nil.
+	IsFailure func(*http.Response, error) bool
+	locker    sync.Mutex
+	circuits  map[string]*circuit
+}
+
+//NewCircuitBreaker create new circuit breaker with default values.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: DefaultCircuitFailureThreshold,
+		CoolDown:         DefaultCircuitCoolDown,
+		HalfOpenRequests: DefaultCircuitHalfOpenRequests,
+		KeyFunc:          CircuitKeyByHost,
+		IsFailure:        IsCircuitFailure,
+		circuits:         map[string]*circuit{},
+	}
+}
+
+//State return circuit state of given key.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	c := b.circuits[key]
+	if c == nil {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.CoolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+//Reset reset circuit of given key to closed state.
+func (b *CircuitBreaker) Reset(key string) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	delete(b.circuits, key)
+}
+
+func (b *CircuitBreaker) key(req *http.Request) string {
+	if b.KeyFunc == nil {
+		return CircuitKeyByHost(req)
+	}
+	return b.KeyFunc(req)
+}
+
+func (b *CircuitBreaker) allow(key string) error {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	if b.circuits == nil {
+		b.circuits = map[string]*circuit{}
+	}
+	c := b.circuits[key]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	if c.state == CircuitOpen {
+		if time.Since(c.openedAt) < b.CoolDown {
+			return &CircuitOpenErr{Key: key, Until: c.openedAt.Add(b.CoolDown)}
+		}
+		c.state = CircuitHalfOpen
+		c.trials = 0
+	}
+	if c.state == CircuitHalfOpen {
+		limit := b.HalfOpenRequests
+		if limit <= 0 {
+			limit = 1
+		}
+		if c.trials >= limit {
+			return &CircuitOpenErr{Key: key, Until: c.openedAt.Add(b.CoolDown)}
+		}
+		c.trials++
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) release(key string) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	c := b.circuits[key]
+	if c != nil && c.trials > 0 {
+		c.trials--
+	}
+}
+
+func (b *CircuitBreaker) report(key string, failed bool) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	c := b.circuits[key]
+	if c == nil {
+		return
+	}
+	if !failed {
+		c.state = CircuitClosed
+		c.failures = 0
+		c.trials = 0
+		return
+	}
+	c.failures++
+	threshold := b.FailureThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if c.state == CircuitHalfOpen || c.failures >= threshold {
+		c.state = CircuitOpen
+		c.openedAt = time.Now()
+		c.trials = 0
+	}
+}
+
+//Wrap wrap given doer with circuit breaker.
+//Wrap can be used as DoerMiddleware.
+func (b *CircuitBreaker) Wrap(d Doer) Doer {
+	return &CircuitBreakerDoer{
+		Doer:    d,
+		Breaker: b,
+	}
+}
+
+//CircuitBreakerDoer doer which fails fast with CircuitOpenErr when circuit is open.
+type CircuitBreakerDoer struct {
+	//Doer doer by which requests will be done.
+	Doer Doer
+	//Breaker circuit breaker
+	Breaker *CircuitBreaker
+}
+
+//Do do http request if circuit is not open.
+//Return http response and any error if raised.
+func (d *CircuitBreakerDoer) Do(req *http.Request) (*http.Response, error) {
+	key := d.Breaker.key(req)
+	err := d.Breaker.allow(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.Doer.Do(req)
+	if err != nil && errors.Is(err, context.Canceled) {
+		d.Breaker.release(key)
+		return resp, err
+	}
+	isFailure := d.Breaker.IsFailure
+	if isFailure == nil {
+		isFailure = IsCircuitFailure
+	}
+	d.Breaker.report(key, isFailure(resp, err))
+	return resp, err
+}
+
+//CircuitBreakerConfig serializable circuit breaker config struct.
+type CircuitBreakerConfig struct {
+	//FailureThreshold consecutive failures which open the circuit.
+	//Default value is 5.
+	FailureThreshold int
+	//CoolDownInSecond duration circuit keeps open before half-open in second.
+	//Default value is 30.
+	CoolDownInSecond int64
+	//HalfOpenRequests trial requests allowed when circuit is half-open.
+	//Default value is 1.
+	HalfOpenRequests int
+}
+
+//Clone clone a new circuit breaker config.
+func (c *CircuitBreakerConfig) Clone() *CircuitBreakerConfig {
+	if c == nil {
+		return nil
+	}
+	cloned := *c
+	return &cloned
+}
+
+//CreateCircuitBreaker create circuit breaker by config.
+func (c *CircuitBreakerConfig) CreateCircuitBreaker() *CircuitBreaker {
+	b := NewCircuitBreaker()
+	if c.FailureThreshold > 0 {
+		b.FailureThreshold = c.FailureThreshold
+	}
+	if c.CoolDownInSecond > 0 {
+		b.CoolDown = time.Duration(c.CoolDownInSecond) * time.Second
+	}
+	if c.HalfOpenRequests > 0 {
+		b.HalfOpenRequests = c.HalfOpenRequests
+	}
+	return b
+}
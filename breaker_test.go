@@ -0,0 +1,153 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	s, count := newFlakyServer(3, 500, nil)
+	defer s.Close()
+	b := NewCircuitBreaker()
+	b.FailureThreshold = 2
+	b.CoolDown = 50 * time.Millisecond
+	doer := b.Wrap(http.DefaultClient)
+	preset := MustPreset(&ServerInfo{URL: s.URL})
+	for i := 0; i < 2; i++ {
+		_, err := DoAndParse(doer, preset, Should200(nil))
+		if !CompareResponseErrStatusCode(err, 500) {
+			t.Fatal(err)
+		}
+	}
+	key := s.Listener.Addr().String()
+	if b.State(key) != CircuitOpen {
+		t.Fatal(b.State(key))
+	}
+	_, err := DoAndParse(doer, preset, Should200(nil))
+	if !IsCircuitOpenErr(err) || IsCircuitOpenErr(errors.New("err")) {
+		t.Fatal(err)
+	}
+	if !IsCircuitOpenErr(fmt.Errorf("wrapped:%w", err)) {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(count) != 2 {
+		t.Fatal(*count)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if b.State(key) != CircuitHalfOpen {
+		t.Fatal(b.State(key))
+	}
+	_, err = DoAndParse(doer, preset, Should200(nil))
+	if !CompareResponseErrStatusCode(err, 500) {
+		t.Fatal(err)
+	}
+	if b.State(key) != CircuitOpen {
+		t.Fatal(b.State(key))
+	}
+	time.Sleep(60 * time.Millisecond)
+	_, err = DoAndParse(doer, preset, Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.State(key) != CircuitClosed {
+		t.Fatal(b.State(key))
+	}
+	b.Reset(key)
+	if b.State(key) != CircuitClosed || CircuitHalfOpen.String() != "half-open" {
+		t.Fatal(b.State(key))
+	}
+}
+
+func TestCircuitBreakerKey(t *testing.T) {
+	b := NewCircuitBreaker()
+	b.FailureThreshold = 1
+	b.KeyFunc = func(req *http.Request) string {
+		return req.Header.Get("key")
+	}
+	d := &errDoer{}
+	doer := b.Wrap(d)
+	_, err := DoAndParse(doer, MustPreset(&ServerInfo{URL: "http://127.0.0.1"}).Concat(SetHeader("key", "a")), nil)
+	if err == nil || IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	_, err = DoAndParse(doer, MustPreset(&ServerInfo{URL: "http://127.0.0.1"}).Concat(SetHeader("key", "a")), nil)
+	if !IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	_, err = DoAndParse(doer, MustPreset(&ServerInfo{URL: "http://127.0.0.1"}).Concat(SetHeader("key", "b")), nil)
+	if err == nil || IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	if d.count != 2 {
+		t.Fatal(d.count)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	s, count := newFlakyServer(10, 503, nil)
+	defer s.Close()
+	server := &Server{
+		ServerInfo: ServerInfo{URL: s.URL},
+		Client: Client{
+			CircuitBreaker: &CircuitBreakerConfig{
+				FailureThreshold: 1,
+			},
+		},
+	}
+	preset := MustPreset(server)
+	_, err := preset.FetchAndParse(Should200(nil))
+	if !CompareResponseErrStatusCode(err, 503) {
+		t.Fatal(err)
+	}
+	_, err = preset.FetchAndParse(Should200(nil))
+	if !IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(count) != 1 {
+		t.Fatal(*count)
+	}
+	other, err := server.CreatePreset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.FetchAndParse(Should200(nil))
+	if !IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	doer, err := server.Client.CreateDoer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DoAndParse(doer, MustPreset(&server.ServerInfo), Should200(nil))
+	if !IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(count) != 1 {
+		t.Fatal(*count)
+	}
+	err = server.Client.Reload(&Client{CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DoAndParse(doer, MustPreset(&server.ServerInfo), Should200(nil))
+	if !IsCircuitOpenErr(err) {
+		t.Fatal(err)
+	}
+	doer, err = server.Client.CreateDoer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DoAndParse(doer, MustPreset(&server.ServerInfo), Should200(nil))
+	if !CompareResponseErrStatusCode(err, 503) {
+		t.Fatal(err)
+	}
+	cloned := server.Client.Clone()
+	cloned.CircuitBreaker.FailureThreshold = 3
+	if server.Client.CircuitBreaker.FailureThreshold != 2 {
+		t.Fatal(cloned)
+	}
+}
@@ -0,0 +1,395 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DefaultCacheMaxEntrySize default max body size of cache entry.
+var DefaultCacheMaxEntrySize int64 = 10 * 1024 * 1024
+
+//DefaultCacheHeuristicMaxAge default max heuristic freshness lifetime of response with Last-Modified header only.
+var DefaultCacheHeuristicMaxAge = 24 * time.Hour
+
+//CacheStatusHeader response header which reports how response is served by CacheDoer.
+var CacheStatusHeader = "X-Fetcher-Cache"
+
+const (
+	//CacheStatusHit cache status of response served from cache without network.
+	CacheStatusHit = "HIT"
+	//CacheStatusRevalidated cache status of response served from cache after revalidation.
+	CacheStatusRevalidated = "REVALIDATED"
+)
+
+var cacheableStatusCodes = map[int]bool{
+	200: true,
+	203: true,
+	204: true,
+	300: true,
+	301: true,
+	404: true,
+	405: true,
+	410: true,
+	414: true,
+	501: true,
+}
+
+type cacheControl map[string]string
+
+func (c cacheControl) has(directive string) bool {
+	_, ok := c[directive]
+	return ok
+}
+
+func (c cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := c[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name := part
+			value := ""
+			if i := strings.Index(part, "="); i >= 0 {
+				name = strings.TrimSpace(part[:i])
+				value = strings.Trim(strings.TrimSpace(part[i+1:]), "\"")
+			}
+			cc[strings.ToLower(name)] = value
+		}
+	}
+	return cc
+}
+
+func varyFields(h http.Header) []string {
+	var fields []string
+	for _, line := range h.Values("Vary") {
+		for _, f := range strings.Split(line, ",") {
+			f = strings.TrimSpace(f)
+			if f != "" {
+				fields = append(fields, http.CanonicalHeaderKey(f))
+			}
+		}
+	}
+	return fields
+}
+
+type cacheEntry struct {
+	StatusCode   int
+	Status       string
+	Header       http.Header
+	Body         []byte
+	VaryHeader   http.Header
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+func (e *cacheEntry) matchVary(req *http.Request) bool {
+	for _, f := range varyFields(e.Header) {
+		if strings.Join(req.Header.Values(f), ",") != strings.Join(e.VaryHeader.Values(f), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *cacheEntry) date() time.Time {
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		return e.ResponseTime
+	}
+	return date
+}
+
+func (e *cacheEntry) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if maxage, ok := cc.seconds("max-age"); ok {
+		return maxage
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(e.date())
+	}
+	lm, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	if err == nil {
+		lifetime := e.date().Sub(lm) / 10
+		if lifetime > DefaultCacheHeuristicMaxAge {
+			lifetime = DefaultCacheHeuristicMaxAge
+		}
+		return lifetime
+	}
+	return 0
+}
+
+func (e *cacheEntry) currentAge(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue, _ := strconv.ParseInt(e.Header.Get("Age"), 10, 64)
+	correctedAge := time.Duration(ageValue)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+	initialAge := apparentAge
+	if correctedAge > initialAge {
+		initialAge = correctedAge
+	}
+	return initialAge + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) fresh(reqcc cacheControl, now time.Time) bool {
+	cc := parseCacheControl(e.Header)
+	if cc.has("no-cache") || reqcc.has("no-cache") {
+		return false
+	}
+	lifetime := e.freshnessLifetime()
+	age := e.currentAge(now)
+	if maxage, ok := reqcc.seconds("max-age"); ok && age > maxage {
+		return false
+	}
+	if minfresh, ok := reqcc.seconds("min-fresh"); ok {
+		age = age + minfresh
+	}
+	if lifetime > age {
+		return true
+	}
+	if reqcc.has("max-stale") && !cc.has("must-revalidate") {
+		maxstale, ok := reqcc.seconds("max-stale")
+		return !ok || age-lifetime <= maxstale
+	}
+	return false
+}
+
+func (e *cacheEntry) update(h http.Header, requestTime time.Time, responseTime time.Time) {
+	for k := range h {
+		if k == "Content-Length" {
+			continue
+		}
+		e.Header[k] = h[k]
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+func (e *cacheEntry) response(req *http.Request, status string, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.currentAge(now)/time.Second), 10))
+	header.Set(CacheStatusHeader, status)
+	return &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func isCacheableResponse(resp *http.Response) bool {
+	if !cacheableStatusCodes[resp.StatusCode] {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") {
+		return false
+	}
+	for _, f := range varyFields(resp.Header) {
+		if f == "*" {
+			return false
+		}
+	}
+	if cc.has("max-age") || cc.has("no-cache") {
+		return true
+	}
+	return resp.Header.Get("Expires") != "" || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+//CacheDoer doer which caches responses by RFC 7234 as a private cache.
+//Only GET responses will be cached.
+//Fresh responses will be served without network,stale responses will be revalidated with conditional requests.
+type CacheDoer struct {
+	//Doer doer by which requests will be done.
+	Doer Doer
+	//Storage cache storage.
+	Storage CacheStorage
+	//MaxEntrySize max body size of cache entry.
+	//Response with larger body will not be cached.
+	MaxEntrySize int64
+	now          func() time.Time
+}
+
+//NewCacheDoer create new cache doer with given doer and storage.
+func NewCacheDoer(d Doer, s CacheStorage) *CacheDoer {
+	return &CacheDoer{
+		Doer:         d,
+		Storage:      s,
+		MaxEntrySize: DefaultCacheMaxEntrySize,
+		now:          time.Now,
+	}
+}
+
+//CacheMiddleware create doer middleware which caches responses in given storage.
+func CacheMiddleware(s CacheStorage) DoerMiddleware {
+	return func(d Doer) Doer {
+		return NewCacheDoer(d, s)
+	}
+}
+
+func (d *CacheDoer) currentTime() time.Time {
+	if d.now == nil {
+		return time.Now()
+	}
+	return d.now()
+}
+
+func (d *CacheDoer) load(key string) *cacheEntry {
+	data, err := d.Storage.Get(key)
+	if err != nil {
+		return nil
+	}
+	e := &cacheEntry{}
+	err = json.Unmarshal(data, e)
+	if err != nil || e.Header == nil {
+		return nil
+	}
+	return e
+}
+
+func (d *CacheDoer) save(key string, e *cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return d.Storage.Set(key, data)
+}
+
+//Do do http request with cache.
+//Return http response and any error if raised.
+func (d *CacheDoer) Do(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	if req.Method != http.MethodGet {
+		resp, err := d.Doer.Do(req)
+		if err == nil && isUnsafeMethod(req.Method) && resp.StatusCode < 400 {
+			d.Storage.Delete(key)
+		}
+		return resp, err
+	}
+	reqcc := parseCacheControl(req.Header)
+	if reqcc.has("no-store") {
+		return d.Doer.Do(req)
+	}
+	entry := d.load(key)
+	if entry != nil && !entry.matchVary(req) {
+		entry = nil
+	}
+	if entry != nil && entry.fresh(reqcc, d.currentTime()) {
+		return entry.response(req, CacheStatusHit, d.currentTime()), nil
+	}
+	if entry == nil && reqcc.has("only-if-cached") {
+		return &http.Response{
+			Status:     "504 Gateway Timeout",
+			StatusCode: http.StatusGatewayTimeout,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	outreq := req
+	if entry != nil && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		etag := entry.Header.Get("ETag")
+		lm := entry.Header.Get("Last-Modified")
+		if etag != "" || lm != "" {
+			outreq = req.Clone(req.Context())
+			if etag != "" {
+				outreq.Header.Set("If-None-Match", etag)
+			}
+			if lm != "" {
+				outreq.Header.Set("If-Modified-Since", lm)
+			}
+		}
+	}
+	requestTime := d.currentTime()
+	resp, err := d.Doer.Do(outreq)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := d.currentTime()
+	if outreq != req && resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		entry.update(resp.Header, requestTime, responseTime)
+		d.save(key, entry)
+		return entry.response(req, CacheStatusRevalidated, responseTime), nil
+	}
+	if !isCacheableResponse(resp) {
+		return resp, nil
+	}
+	limit := d.MaxEntrySize
+	if limit <= 0 {
+		limit = DefaultCacheMaxEntrySize
+	}
+	if resp.ContentLength > limit {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry = &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		VaryHeader:   http.Header{},
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	for _, f := range varyFields(resp.Header) {
+		entry.VaryHeader[f] = req.Header.Values(f)
+	}
+	d.save(key, entry)
+	return resp, nil
+}
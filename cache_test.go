@@ -0,0 +1,136 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCacheTestServer(header http.Header) (*httptest.Server, *int32) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		for k, v := range header {
+			w.Header()[http.CanonicalHeaderKey(k)] = v
+		}
+		etag := w.Header().Get("ETag")
+		if etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method != "GET" {
+			w.Write([]byte(r.Method))
+			return
+		}
+		w.Write([]byte("body" + strconv.Itoa(int(n)) + r.Header.Get("Accept-Language")))
+	}))
+	return s, &count
+}
+
+func fetchCached(t *testing.T, d Doer, url string, cmds ...Command) (string, string) {
+	var result string
+	resp, err := DoAndParse(d, BuildPreset(URL(url)).Concat(cmds...), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, resp.Header.Get(CacheStatusHeader)
+}
+
+func TestCacheDoerMaxAge(t *testing.T) {
+	s, count := newCacheTestServer(http.Header{"Cache-Control": []string{"max-age=60"}})
+	defer s.Close()
+	now := time.Now()
+	d := NewCacheDoer(http.DefaultClient, NewMemoryCacheStorage(10))
+	d.now = func() time.Time { return now }
+	body, status := fetchCached(t, d, s.URL)
+	if body != "body1" || status != "" {
+		t.Fatal(body, status)
+	}
+	body, status = fetchCached(t, d, s.URL)
+	if body != "body1" || status != CacheStatusHit || atomic.LoadInt32(count) != 1 {
+		t.Fatal(body, status)
+	}
+	body, status = fetchCached(t, d, s.URL, SetHeader("Cache-Control", "no-cache"))
+	if body != "body2" || status != "" {
+		t.Fatal(body, status)
+	}
+	now = now.Add(2 * time.Minute)
+	body, _ = fetchCached(t, d, s.URL)
+	if body != "body3" {
+		t.Fatal(body)
+	}
+	_, err := DoAndParse(d, BuildPreset(URL(s.URL), Post), Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = fetchCached(t, d, s.URL)
+	if body != "body5" {
+		t.Fatal(body)
+	}
+}
+
+func TestCacheDoerRevalidate(t *testing.T) {
+	s, count := newCacheTestServer(http.Header{"ETag": []string{`"v1"`}, "Cache-Control": []string{"no-cache"}})
+	defer s.Close()
+	d := NewCacheDoer(http.DefaultClient, NewMemoryCacheStorage(10))
+	body, _ := fetchCached(t, d, s.URL)
+	if body != "body1" {
+		t.Fatal(body)
+	}
+	body, status := fetchCached(t, d, s.URL)
+	if body != "body1" || status != CacheStatusRevalidated || atomic.LoadInt32(count) != 2 {
+		t.Fatal(body, status)
+	}
+}
+
+func TestCacheDoerVary(t *testing.T) {
+	s, _ := newCacheTestServer(http.Header{"Cache-Control": []string{"max-age=60"}, "Vary": []string{"Accept-Language"}})
+	defer s.Close()
+	d := NewCacheDoer(http.DefaultClient, NewMemoryCacheStorage(10))
+	body, _ := fetchCached(t, d, s.URL, SetHeader("Accept-Language", "en"))
+	if body != "body1en" {
+		t.Fatal(body)
+	}
+	body, _ = fetchCached(t, d, s.URL, SetHeader("Accept-Language", "zh"))
+	if body != "body2zh" {
+		t.Fatal(body)
+	}
+	body, status := fetchCached(t, d, s.URL, SetHeader("Accept-Language", "zh"))
+	if body != "body2zh" || status != CacheStatusHit {
+		t.Fatal(body, status)
+	}
+}
+
+func TestCacheDoerNoStore(t *testing.T) {
+	s, count := newCacheTestServer(http.Header{"Cache-Control": []string{"no-store"}})
+	defer s.Close()
+	storage := NewMemoryCacheStorage(10)
+	d := NewCacheDoer(http.DefaultClient, storage)
+	fetchCached(t, d, s.URL)
+	fetchCached(t, d, s.URL)
+	if atomic.LoadInt32(count) != 2 || storage.Len() != 0 {
+		t.Fatal(*count)
+	}
+	resp, err := DoAndParse(d, BuildPreset(URL(s.URL+"/notcached"), SetHeader("Cache-Control", "only-if-cached")), AsBodyContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatal(resp)
+	}
+}
+
+func TestCacheDoerMaxEntrySize(t *testing.T) {
+	s, _ := newCacheTestServer(http.Header{"Cache-Control": []string{"max-age=60"}})
+	defer s.Close()
+	storage := NewMemoryCacheStorage(10)
+	d := NewCacheDoer(http.DefaultClient, storage)
+	d.MaxEntrySize = 2
+	body, _ := fetchCached(t, d, s.URL)
+	if body != "body1" || storage.Len() != 0 {
+		t.Fatal(body)
+	}
+}
@@ -0,0 +1,173 @@
+package fetcher
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//ErrCacheNotFound error raised when cache entry not found.
+var ErrCacheNotFound = errors.New("fetcher:cache not found")
+
+//CacheStorage cache storage interface used by CacheDoer.
+type CacheStorage interface {
+	//Get get cache data by given key.
+	//Return data and any error if raised.
+	//ErrCacheNotFound should be returned if key not found.
+	Get(key string) ([]byte, error)
+	//Set set cache data by given key.
+	//Return any error if raised.
+	Set(key string, data []byte) error
+	//Delete delete cache data by given key.
+	//Return any error if raised.
+	Delete(key string) error
+}
+
+type memoryCacheItem struct {
+	key  string
+	data []byte
+}
+
+//MemoryCacheStorage in-memory cache storage with LRU eviction.
+type MemoryCacheStorage struct {
+	maxEntries int
+	locker     sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List
+}
+
+//NewMemoryCacheStorage create new in-memory cache storage with given max entries.
+//Entries will not be evicted if maxEntries less than or equal to 0.
+func NewMemoryCacheStorage(maxEntries int) *MemoryCacheStorage {
+	return &MemoryCacheStorage{
+		maxEntries: maxEntries,
+		items:      map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+//Get get cache data by given key.
+//Return data and any error if raised.
+func (s *MemoryCacheStorage) Get(key string) ([]byte, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, ErrCacheNotFound
+	}
+	s.lru.MoveToFront(e)
+	return e.Value.(*memoryCacheItem).data, nil
+}
+
+//Set set cache data by given key.
+//Least recently used entry will be evicted if storage is full.
+//Return any error if raised.
+func (s *MemoryCacheStorage) Set(key string, data []byte) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	e, ok := s.items[key]
+	if ok {
+		e.Value.(*memoryCacheItem).data = data
+		s.lru.MoveToFront(e)
+		return nil
+	}
+	s.items[key] = s.lru.PushFront(&memoryCacheItem{key: key, data: data})
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		last := s.lru.Back()
+		s.lru.Remove(last)
+		delete(s.items, last.Value.(*memoryCacheItem).key)
+	}
+	return nil
+}
+
+//Delete delete cache data by given key.
+//Return any error if raised.
+func (s *MemoryCacheStorage) Delete(key string) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	e, ok := s.items[key]
+	if ok {
+		s.lru.Remove(e)
+		delete(s.items, key)
+	}
+	return nil
+}
+
+//Len return count of entries in storage.
+func (s *MemoryCacheStorage) Len() int {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.lru.Len()
+}
+
+//DiskCacheStorage on-disk cache storage which stores every entry in a file.
+type DiskCacheStorage struct {
+	//Dir directory where cache files stored.
+	Dir string
+}
+
+//NewDiskCacheStorage create new on-disk cache storage in given directory.
+//Directory will be created if not exists.
+//Return storage created and any error if raised.
+func NewDiskCacheStorage(dir string) (*DiskCacheStorage, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskCacheStorage{Dir: dir}, nil
+}
+
+func (s *DiskCacheStorage) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]))
+}
+
+//Get get cache data by given key.
+//Return data and any error if raised.
+func (s *DiskCacheStorage) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.filename(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrCacheNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+//Set set cache data by given key.
+//Cache file will be replaced atomically.
+//Return any error if raised.
+func (s *DiskCacheStorage) Set(key string, data []byte) error {
+	tmp, err := ioutil.TempFile(s.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename(key))
+}
+
+//Delete delete cache data by given key.
+//Return any error if raised.
+func (s *DiskCacheStorage) Delete(key string) error {
+	err := os.Remove(s.filename(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package fetcher
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testCacheStorage(t *testing.T, s CacheStorage) {
+	_, err := s.Get("key")
+	if err != ErrCacheNotFound {
+		t.Fatal(err)
+	}
+	err = s.Set("key", []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get("key")
+	if err != nil || string(data) != "value" {
+		t.Fatal(string(data), err)
+	}
+	err = s.Set("key", []byte("value2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = s.Get("key")
+	if err != nil || string(data) != "value2" {
+		t.Fatal(string(data), err)
+	}
+	err = s.Delete("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get("key")
+	if err != ErrCacheNotFound {
+		t.Fatal(err)
+	}
+	err = s.Delete("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryCacheStorage(t *testing.T) {
+	testCacheStorage(t, NewMemoryCacheStorage(10))
+	s := NewMemoryCacheStorage(2)
+	s.Set("k1", []byte("1"))
+	s.Set("k2", []byte("2"))
+	s.Get("k1")
+	s.Set("k3", []byte("3"))
+	if s.Len() != 2 {
+		t.Fatal(s.Len())
+	}
+	_, err := s.Get("k2")
+	if err != ErrCacheNotFound {
+		t.Fatal(err)
+	}
+	_, err = s.Get("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskCacheStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchercache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewDiskCacheStorage(dir + "/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCacheStorage(t, s)
+}
@@ -0,0 +1,340 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//CassetteMode cassette doer mode type
+type CassetteMode int
+
+const (
+	//CassetteModePassthrough cassette mode which do requests by doer without recording.
+	CassetteModePassthrough = CassetteMode(iota)
+	//CassetteModeRecord cassette mode which do requests by doer and record interactions to cassette file.
+	CassetteModeRecord
+	//CassetteModeReplay cassette mode which serve responses from cassette file without network.
+	CassetteModeReplay
+)
+
+//ErrCassetteInteractionNotFound error raised when no recorded interaction matches request in replay mode.
+var ErrCassetteInteractionNotFound = errors.New("fetcher:cassette interaction not found")
+
+//CassetteRedactedValue value which redacted header values replaced with.
+var CassetteRedactedValue = "[REDACTED]"
+
+//DefaultCassetteRedactedHeaders default headers which will be redacted before writing.
+var DefaultCassetteRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+//CassetteBody recorded body.
+//Body which is not valid utf8 will be stored in base64.
+type CassetteBody struct {
+	//Text body text.
+	Text string
+	//Base64 body in base64 encoding.
+	Base64 string
+}
+
+//NewCassetteBody create new cassette body with given data.
+func NewCassetteBody(data []byte) CassetteBody {
+	if utf8.Valid(data) {
+		return CassetteBody{Text: string(data)}
+	}
+	return CassetteBody{Base64: base64.StdEncoding.EncodeToString(data)}
+}
+
+//Bytes return body data.
+func (b CassetteBody) Bytes() []byte {
+	if b.Base64 != "" {
+		data, err := base64.StdEncoding.DecodeString(b.Base64)
+		if err == nil {
+			return data
+		}
+	}
+	return []byte(b.Text)
+}
+
+//CassetteRequest recorded request.
+type CassetteRequest struct {
+	//Method request method.
+	Method string
+	//URL request url.
+	URL string
+	//Header request header.
+	Header http.Header
+	//Body request body.
+	Body CassetteBody
+}
+
+//CassetteResponse recorded response.
+type CassetteResponse struct {
+	//StatusCode response status code.
+	StatusCode int
+	//Status response status.
+	Status string
+	//Header response header.
+	Header http.Header
+	//Body response body.
+	Body CassetteBody
+}
+
+//CassetteInteraction recorded request/response pair.
+type CassetteInteraction struct {
+	//Request recorded request.
+	Request *CassetteRequest
+	//Response recorded response.
+	Response *CassetteResponse
+}
+
+//Cassette cassette struct which contains recorded interactions.
+type Cassette struct {
+	//Interactions recorded interactions in order.
+	Interactions []*CassetteInteraction
+}
+
+//LoadCassette load cassette from given json file.
+//Return cassette loaded and any error if raised.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//Save save cassette to given file in json format.
+//Return any error if raised.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+//CassetteMatcher cassette request matcher.
+type CassetteMatcher struct {
+	//Method whether request method should match.
+	Method bool
+	//URL whether request url should match.
+	URL bool
+	//Headers request headers which should match.
+	//Redacted headers only need to be present.
+	Headers []string
+	//Body whether request body should match.
+	Body bool
+}
+
+//NewCassetteMatcher create new cassette matcher which matches method and url.
+func NewCassetteMatcher() *CassetteMatcher {
+	return &CassetteMatcher{
+		Method: true,
+		URL:    true,
+	}
+}
+
+//Match check if recorded request matches given request and body.
+func (m *CassetteMatcher) Match(recorded *CassetteRequest, req *http.Request, body []byte) bool {
+	if m.Method && recorded.Method != req.Method {
+		return false
+	}
+	if m.URL && recorded.URL != req.URL.String() {
+		return false
+	}
+	for _, name := range m.Headers {
+		values := recorded.Header.Values(name)
+		if len(values) == 1 && values[0] == CassetteRedactedValue {
+			if len(req.Header.Values(name)) == 0 {
+				return false
+			}
+			continue
+		}
+		if strings.Join(values, ",") != strings.Join(req.Header.Values(name), ",") {
+			return false
+		}
+	}
+	if m.Body && !bytes.Equal(recorded.Body.Bytes(), body) {
+		return false
+	}
+	return true
+}
+
+//CassetteDoer record/replay doer for deterministic tests.
+type CassetteDoer struct {
+	//Doer doer by which requests will be done in record and passthrough mode.
+	Doer Doer
+	//Mode cassette mode.
+	Mode CassetteMode
+	//Path cassette file path.
+	Path string
+	//Matcher request matcher used in replay mode.
+	Matcher *CassetteMatcher
+	//RedactHeaders headers which will be redacted before writing.
+	RedactHeaders []string
+	locker        sync.Mutex
+	cassette      *Cassette
+	used          map[int]bool
+}
+
+//NewCassetteDoer create new cassette doer with given doer,mode and cassette file path.
+func NewCassetteDoer(d Doer, mode CassetteMode, path string) *CassetteDoer {
+	headers := make([]string, len(DefaultCassetteRedactedHeaders))
+	copy(headers, DefaultCassetteRedactedHeaders)
+	return &CassetteDoer{
+		Doer:          d,
+		Mode:          mode,
+		Path:          path,
+		Matcher:       NewCassetteMatcher(),
+		RedactHeaders: headers,
+	}
+}
+
+func (d *CassetteDoer) redact(h http.Header) http.Header {
+	result := h.Clone()
+	if result == nil {
+		result = http.Header{}
+	}
+	for _, name := range d.RedactHeaders {
+		if len(result.Values(name)) > 0 {
+			result.Set(name, CassetteRedactedValue)
+		}
+	}
+	return result
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+//Do do http request by cassette mode.
+//Return http response and any error if raised.
+func (d *CassetteDoer) Do(req *http.Request) (*http.Response, error) {
+	switch d.Mode {
+	case CassetteModeRecord:
+		return d.record(req)
+	case CassetteModeReplay:
+		return d.replay(req)
+	}
+	return d.Doer.Do(req)
+}
+
+func (d *CassetteDoer) record(req *http.Request) (*http.Response, error) {
+	reqbody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.Doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respbody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respbody))
+	i := &CassetteInteraction{
+		Request: &CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: d.redact(req.Header),
+			Body:   NewCassetteBody(reqbody),
+		},
+		Response: &CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     d.redact(resp.Header),
+			Body:       NewCassetteBody(respbody),
+		},
+	}
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.cassette == nil {
+		d.cassette = &Cassette{}
+	}
+	d.cassette.Interactions = append(d.cassette.Interactions, i)
+	err = d.cassette.Save(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (d *CassetteDoer) replay(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.cassette == nil {
+		c, err := LoadCassette(d.Path)
+		if err != nil {
+			return nil, err
+		}
+		d.cassette = c
+	}
+	if d.used == nil {
+		d.used = map[int]bool{}
+	}
+	matcher := d.Matcher
+	if matcher == nil {
+		matcher = NewCassetteMatcher()
+	}
+	found := -1
+	for k, v := range d.cassette.Interactions {
+		if !matcher.Match(v.Request, req, body) {
+			continue
+		}
+		if !d.used[k] {
+			found = k
+			break
+		}
+		if found < 0 {
+			found = k
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("%w [%s %s]", ErrCassetteInteractionNotFound, req.Method, req.URL.String())
+	}
+	d.used[found] = true
+	r := d.cassette.Interactions[found].Response
+	respbody := r.Body.Bytes()
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        r.Status,
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(respbody)),
+		ContentLength: int64(len(respbody)),
+		Request:       req,
+	}, nil
+}
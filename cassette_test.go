@@ -0,0 +1,120 @@
+package fetcher
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteDoer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchercassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+	s := newEchoServer()
+	preset := MustPreset(&ServerInfo{URL: s.URL, Method: "POST"}).With(BasicAuth("user", "secret"))
+	recorder := NewCassetteDoer(nil, CassetteModeRecord, path)
+	recorder.Doer = DefaultDoer()
+	for _, body := range []string{"first", "second", string([]byte{0xff, 0xfe})} {
+		var result string
+		_, err = DoWithBodyAndParse(recorder, preset, bytes.NewBufferString(body), Should200(AsString(&result)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != body {
+			t.Fatal(result)
+		}
+	}
+	s.Close()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "c2VjcmV0") || !strings.Contains(string(data), CassetteRedactedValue) {
+		t.Fatal(string(data))
+	}
+	c, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 3 || !bytes.Equal(c.Interactions[2].Response.Body.Bytes(), []byte{0xff, 0xfe}) {
+		t.Fatal(c)
+	}
+
+	player := NewCassetteDoer(nil, CassetteModeReplay, path)
+	var result string
+	for _, body := range []string{"first", "second", string([]byte{0xff, 0xfe}), "first"} {
+		_, err = DoWithBodyAndParse(player, preset, bytes.NewBufferString("any"), Should200(AsString(&result)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != body {
+			t.Fatal(result)
+		}
+	}
+	player = NewCassetteDoer(nil, CassetteModeReplay, path)
+	player.Matcher.Body = true
+	player.Matcher.Headers = []string{"Authorization"}
+	_, err = DoWithBodyAndParse(player, preset, bytes.NewBufferString("second"), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "second" {
+		t.Fatal(result)
+	}
+	_, err = DoWithBodyAndParse(player, preset, bytes.NewBufferString("unknown"), Should200(AsString(&result)))
+	if !errors.Is(err, ErrCassetteInteractionNotFound) {
+		t.Fatal(err)
+	}
+	_, err = DoWithBodyAndParse(player, MustPreset(&ServerInfo{URL: s.URL, Method: "POST"}), bytes.NewBufferString("second"), Should200(AsString(&result)))
+	if !errors.Is(err, ErrCassetteInteractionNotFound) {
+		t.Fatal(err)
+	}
+}
+
+func TestCassettePassthrough(t *testing.T) {
+	s := newEchoServer()
+	defer s.Close()
+	d := NewCassetteDoer(DefaultDoer(), CassetteModePassthrough, "")
+	var result string
+	_, err := DoWithBodyAndParse(d, MustPreset(&ServerInfo{URL: s.URL, Method: "POST"}), bytes.NewBufferString("body"), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "body" {
+		t.Fatal(result)
+	}
+}
+
+func TestCassetteRecordThenReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchercassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := newEchoServer()
+	preset := MustPreset(&ServerInfo{URL: s.URL, Method: "POST"})
+	d := NewCassetteDoer(DefaultDoer(), CassetteModeRecord, filepath.Join(dir, "cassette.json"))
+	_, err = DoWithBodyAndParse(d, preset, bytes.NewBufferString("recorded"), DefaultParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	d.Mode = CassetteModeReplay
+	var result string
+	for i := 0; i < 2; i++ {
+		_, err = DoWithBodyAndParse(d, preset, bytes.NewBufferString("recorded"), Should200(AsString(&result)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != "recorded" {
+			t.Fatal(result)
+		}
+	}
+}
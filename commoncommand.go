@@ -2,7 +2,9 @@ package fetcher
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -15,6 +17,7 @@ import (
 func ParsedURL(u *url.URL) Command {
 	return CommandFunc(func(f *Fetcher) error {
 		f.URL = u
+		f.URLTemplate = ""
 		return nil
 	})
 }
@@ -27,6 +30,7 @@ func URL(u string) Command {
 			return err
 		}
 		f.URL = furl
+		f.URLTemplate = ""
 		return nil
 	})
 }
@@ -53,11 +57,16 @@ var (
 )
 
 //PathPrefix command which modify fetcher url with given path prefix
+//If fetcher url template is set,prefix will be inserted before path of template.
 type PathPrefix string
 
 //Exec exec command to modify fetcher.
 //Return any error if raised.
 func (p PathPrefix) Exec(f *Fetcher) error {
+	if f.URLTemplate != "" {
+		f.URLTemplate = prefixURITemplatePath(f.URLTemplate, escapeURITemplateLiteral(string(p)))
+		return nil
+	}
 	f.URL.Path = string(p) + f.URL.Path
 	return nil
 }
@@ -93,42 +102,58 @@ func (h Host) Exec(f *Fetcher) error {
 }
 
 //Replace command which modify fetcher path by given placeholder and value.
+//If fetcher url template is set,placeholder like "{name}" will be set as template var,
+//other placeholders will be replaced in template.
 func Replace(placeholder string, value string) Command {
 	return CommandFunc(func(f *Fetcher) error {
+		if f.URLTemplate != "" {
+			if name := uriTemplateVarName(placeholder); name != "" {
+				f.TemplateVars = mergeTemplateVars(f.TemplateVars, map[string]interface{}{name: value})
+			} else {
+				f.URLTemplate = strings.Replace(f.URLTemplate, placeholder, escapeURITemplateLiteral(value), -1)
+			}
+		}
 		f.URL.Path = strings.NewReplacer(placeholder, value).Replace(f.URL.Path)
 		return nil
 	})
 }
 
 //Body command which modify fetcher body to given reader.
+//Fetcher body source will be set to nil.
+//Reader can only be read once,use BytesBody,StringBody,FileBody or BodyFunc for replayable body.
 func Body(body io.Reader) Command {
 	return CommandFunc(func(f *Fetcher) error {
 		f.Body = body
+		f.BodySource = nil
 		return nil
 	})
 }
 
 //JSONBody command which modify fetcher body to given value as json.
+//Body can be replayed.
 //Fetcher body will set to nil if v is nil.
 func JSONBody(v interface{}) Command {
 	return CommandFunc(func(f *Fetcher) error {
 		if v == nil {
 			f.Body = nil
+			f.BodySource = nil
 			return nil
 		}
 		bs, err := json.Marshal(v)
 		if err != nil {
 			return err
 		}
-		f.Body = bytes.NewBuffer(bs)
+		f.Body = nil
+		f.BodySource = BytesSource(bs)
 		return nil
 	})
 }
 
-//Header command which merge fetcher header by given reader.
+//Header command which merge fetcher header by given header.
+//All values of given header will replace existing values,same as HeaderWithMode with HeaderMergeReplace.
 func Header(h http.Header) Command {
 	return CommandFunc(func(f *Fetcher) error {
-		MergeHeader(f.Header, h)
+		MergeHeaderWithMode(f.Header, h, HeaderMergeReplace)
 		return nil
 	})
 }
@@ -141,6 +166,54 @@ func SetHeader(key string, value string) Command {
 	})
 }
 
+//HeaderWithMode command which merge fetcher header by given header and merge mode.
+func HeaderWithMode(h http.Header, mode HeaderMergeMode) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		MergeHeaderWithMode(f.Header, h, mode)
+		return nil
+	})
+}
+
+//AddHeader command which add given values to fetcher header by given key.
+//Existing values will be kept.
+func AddHeader(key string, values ...string) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		for _, v := range values {
+			f.Header.Add(key, v)
+		}
+		return nil
+	})
+}
+
+//DelHeader command which delete fetcher header by given key.
+func DelHeader(key string) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		f.Header.Del(key)
+		return nil
+	})
+}
+
+//Cookie command which add cookie with given name and value to fetcher header.
+func Cookie(name string, value string) Command {
+	return AddCookie(&http.Cookie{Name: name, Value: value})
+}
+
+//AddCookie command which add given cookie to fetcher header.
+//Only name and value of cookie will be sent.
+func AddCookie(c *http.Cookie) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		s := (&http.Cookie{Name: c.Name, Value: c.Value}).String()
+		if s == "" {
+			return fmt.Errorf("fetcher:invalid cookie name %q", c.Name)
+		}
+		if existing := f.Header.Get("Cookie"); existing != "" {
+			s = existing + "; " + s
+		}
+		f.Header.Set("Cookie", s)
+		return nil
+	})
+}
+
 //SetDoer command which modify fetcher doer to given doer.
 func SetDoer(d Doer) Command {
 	return CommandFunc(func(f *Fetcher) error {
@@ -149,6 +222,15 @@ func SetDoer(d Doer) Command {
 	})
 }
 
+//SetContext command which modify fetcher context to given context.
+//Request builders,doer and parsers will all see the context via http request.
+func SetContext(ctx context.Context) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		f.Context = ctx
+		return nil
+	})
+}
+
 //SetQuery command which modify fetcher to set given query.
 func SetQuery(name string, value string) Command {
 	return CommandFunc(func(f *Fetcher) error {
@@ -159,7 +241,84 @@ func SetQuery(name string, value string) Command {
 	})
 }
 
+//AddQuery command which add given values to fetcher query by given name.
+//Existing values will be kept.
+func AddQuery(name string, values ...string) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		q := f.URL.Query()
+		for _, v := range values {
+			q.Add(name, v)
+		}
+		f.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+//DelQuery command which delete fetcher query by given name.
+func DelQuery(name string) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		q := f.URL.Query()
+		q.Del(name)
+		f.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+//ReplaceQuery command which replace fetcher query with all values of given params.
+//Queries not in params will be kept.
+func ReplaceQuery(params url.Values) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		q := f.URL.Query()
+		for key := range params {
+			q[key] = append([]string{}, params[key]...)
+		}
+		f.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+//ArrayQueryStyle array query encoding style type
+type ArrayQueryStyle int
+
+const (
+	//ArrayQueryRepeat encode array query as repeated name,like "tag=a&tag=b".
+	ArrayQueryRepeat = ArrayQueryStyle(iota)
+	//ArrayQueryBrackets encode array query as name with brackets,like "tag[]=a&tag[]=b".
+	ArrayQueryBrackets
+	//ArrayQueryComma encode array query as comma joined value,like "tag=a,b".
+	ArrayQueryComma
+)
+
+//ArrayQuery command which set fetcher query by given name,values and encoding style.
+//Existing values of query will be replaced,query will be deleted if values is empty.
+func ArrayQuery(name string, values []string, style ArrayQueryStyle) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		q := f.URL.Query()
+		switch style {
+		case ArrayQueryBrackets:
+			key := name + "[]"
+			q.Del(key)
+			for _, v := range values {
+				q.Add(key, v)
+			}
+		case ArrayQueryComma:
+			q.Del(name)
+			if len(values) > 0 {
+				q.Set(name, strings.Join(values, ","))
+			}
+		default:
+			q.Del(name)
+			for _, v := range values {
+				q.Add(name, v)
+			}
+		}
+		f.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
 //Params command which modify fetcher to set given params.
+//Only first value of every param will be set,use ReplaceQuery to keep multiple values.
 func Params(params url.Values) Command {
 	return CommandFunc(func(f *Fetcher) error {
 		q := f.URL.Query()
@@ -283,6 +442,7 @@ type MultiPartWriter struct {
 //Return any error if raised.
 func (w *MultiPartWriter) Exec(f *Fetcher) error {
 	f.Body = w.body
+	f.BodySource = nil
 	f.Header.Set("Content-Type", w.FormDataContentType())
 	return nil
 }
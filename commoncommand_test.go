@@ -343,3 +343,123 @@ func TestMultiPartWriter(t *testing.T) {
 	}
 
 }
+
+func TestCookie(t *testing.T) {
+	f := New()
+	err := Cookie("k1", "v1").Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddCookie(&http.Cookie{Name: "k2", Value: "v 2", Path: "/ignored"}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Header.Get("Cookie") != `k1=v1; k2="v 2"` {
+		t.Fatal(f.Header.Get("Cookie"))
+	}
+	err = Cookie("", "v").Exec(f)
+	if err == nil {
+		t.Fatal(err)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := req.Cookie("k2")
+	if err != nil || c.Value != "v 2" {
+		t.Fatal(c, err)
+	}
+}
+
+func TestMultiValueQuery(t *testing.T) {
+	f := New()
+	err := Exec(f, URL("http://127.0.0.1/?keep=1&tag=old"), AddQuery("tag", "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.RawQuery != "keep=1&tag=old&tag=a&tag=b" {
+		t.Fatal(f.URL.RawQuery)
+	}
+	err = ReplaceQuery(url.Values{"tag": []string{"x", "y"}, "new": []string{"1"}}).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.RawQuery != "keep=1&new=1&tag=x&tag=y" {
+		t.Fatal(f.URL.RawQuery)
+	}
+	err = DelQuery("new").Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.RawQuery != "keep=1&tag=x&tag=y" {
+		t.Fatal(f.URL.RawQuery)
+	}
+	var cases = map[ArrayQueryStyle]string{
+		ArrayQueryRepeat:   "id=1&id=2",
+		ArrayQueryBrackets: "id%5B%5D=1&id%5B%5D=2",
+		ArrayQueryComma:    "id=1%2C2",
+	}
+	for style, expected := range cases {
+		f = New()
+		cmd := ArrayQuery("id", []string{"1", "2"}, style)
+		err = Exec(f, cmd, cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.URL.RawQuery != expected {
+			t.Fatal(style, f.URL.RawQuery)
+		}
+	}
+	for style := range cases {
+		f = New()
+		err = Exec(f, ArrayQuery("id", []string{"1", "2"}, style), ArrayQuery("id", nil, style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.URL.RawQuery != "" {
+			t.Fatal(style, f.URL.RawQuery)
+		}
+	}
+}
+
+func TestMultiValueHeader(t *testing.T) {
+	f := New()
+	err := Exec(f, SetHeader("Accept", "text/html"), AddHeader("Accept", "text/plain", "application/json"), SetHeader("X-Remove", "1"), DelHeader("x-remove"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Header["Accept"]) != 3 || f.Header.Get("X-Remove") != "" {
+		t.Fatal(f.Header)
+	}
+	h := http.Header{}
+	h.Add("accept", "a")
+	h.Add("accept", "b")
+	err = HeaderWithMode(h, HeaderMergeReplace).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(f.Header["Accept"], ",") != "a,b" {
+		t.Fatal(f.Header)
+	}
+	err = HeaderWithMode(h, HeaderMergeAppend).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(f.Header["Accept"], ",") != "a,b,a,b" {
+		t.Fatal(f.Header)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(req.Header["Accept"], ",") != "a,b,a,b" {
+		t.Fatal(req.Header)
+	}
+	err = HeaderWithMode(h, HeaderMergeSet).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(f.Header["Accept"], ",") != "b" {
+		t.Fatal(f.Header)
+	}
+}
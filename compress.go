@@ -0,0 +1,90 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	//CompressEncodingGzip gzip content encoding.
+	CompressEncodingGzip = "gzip"
+	//CompressEncodingDeflate deflate content encoding,which is zlib format as defined in RFC 7230.
+	CompressEncodingDeflate = "deflate"
+)
+
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case CompressEncodingGzip:
+		return gzip.NewWriter(w), nil
+	case CompressEncodingDeflate:
+		return zlib.NewWriter(w), nil
+	}
+	return nil, fmt.Errorf("fetcher:unsupported content encoding %q", encoding)
+}
+
+func compressReader(encoding string, src io.ReadCloser) io.ReadCloser {
+	return newLazyPipeReader(func(pw *io.PipeWriter) {
+		defer src.Close()
+		w, err := newCompressWriter(encoding, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		_, err = io.Copy(w, src)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Close())
+	}, func() {
+		src.Close()
+	})
+}
+
+//CompressBody command which compresses request body in given encoding and sets Content-Encoding header.
+//Supported encodings are "gzip" and "deflate".
+//Body will be compressed in streaming when request sent,whichever command set it and wherever this command placed.
+//Body smaller than threshold in bytes will not be compressed,body with unknown size will always be compressed.
+//Content-Length will be unknown after compressed,replayable body will still be replayable.
+func CompressBody(encoding string, threshold int64) Command {
+	encoding = strings.ToLower(encoding)
+	return CommandFunc(func(f *Fetcher) error {
+		_, err := newCompressWriter(encoding, ioutil.Discard)
+		if err != nil {
+			return err
+		}
+		f.AppendBuilder(func(req *http.Request) error {
+			if req.Body == nil || req.Body == http.NoBody {
+				return nil
+			}
+			if req.ContentLength > 0 && req.ContentLength < threshold {
+				return nil
+			}
+			if req.Header.Get("Content-Encoding") != "" {
+				return fmt.Errorf("fetcher:request body already encoded in %q", req.Header.Get("Content-Encoding"))
+			}
+			req.Body = compressReader(encoding, req.Body)
+			req.ContentLength = -1
+			if getbody := req.GetBody; getbody != nil {
+				req.GetBody = func() (io.ReadCloser, error) {
+					body, err := getbody()
+					if err != nil {
+						return nil, err
+					}
+					if body == http.NoBody {
+						return body, nil
+					}
+					return compressReader(encoding, body), nil
+				}
+			}
+			req.Header.Set("Content-Encoding", encoding)
+			return nil
+		})
+		return nil
+	})
+}
@@ -0,0 +1,138 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newCompressServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		var err error
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			reader, err = gzip.NewReader(r.Body)
+		case "deflate":
+			reader, err = zlib.NewReader(r.Body)
+		}
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Content-Encoding") + ":" + strconv.FormatInt(r.ContentLength, 10) + ":" + string(data)))
+	}))
+}
+
+func TestCompressBody(t *testing.T) {
+	server := newCompressServer()
+	defer server.Close()
+	var result string
+	_, err := FetchAndParse(NewPreset().With(URL(server.URL), Post, CompressBody("GZIP", 0), JSONBody("data")), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != `gzip:-1:"data"` {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, Body(strings.NewReader("deflated")), CompressBody("deflate", 4)), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "deflate:-1:deflated" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, StringBody("small"), CompressBody("gzip", 1024)), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != ":5:small" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), CompressBody("gzip", 0)), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != ":0:" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, CompressBody("br", 0)), AsUselessBody)
+	if err == nil {
+		t.Fatal(err)
+	}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, StringBody("data"), SetHeader("Content-Encoding", "gzip"), CompressBody("gzip", 0)), AsUselessBody)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressBodyReplay(t *testing.T) {
+	f := New()
+	err := Exec(f, Post, StringBody(strings.Repeat("data", 100)), CompressBody("gzip", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != -1 || req.Header.Get("Content-Encoding") != "gzip" || req.GetBody == nil {
+		t.Fatal(req)
+	}
+	for i := 0; i < 2; i++ {
+		body, err := req.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil || string(data) != strings.Repeat("data", 100) {
+			t.Fatal(string(data), err)
+		}
+		body.Close()
+	}
+	err = req.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locker sync.Mutex
+	attempts := 0
+	server := newCompressServer()
+	defer server.Close()
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		attempts++
+		n := attempts
+		locker.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, server.URL, http.StatusTemporaryRedirect)
+	}))
+	defer flaky.Close()
+	client := &Client{Retry: &RetryConfig{BackoffInMillisecond: 1, RetryNonIdempotent: true}}
+	var result string
+	_, err = FetchAndParse(NewPreset().With(client, Post, URL(flaky.URL), CompressBody("deflate", 0), StringBody("replayed")), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "deflate:-1:replayed" {
+		t.Fatal(result)
+	}
+}
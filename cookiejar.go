@@ -0,0 +1,233 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//CookieJarEntry cookie recorded by cookie jar.
+type CookieJarEntry struct {
+	//URL url which cookie set from.
+	URL string
+	//Name cookie name.
+	Name string
+	//Value cookie value.
+	Value string
+	//Domain cookie domain.
+	Domain string `json:",omitempty"`
+	//Path cookie path.
+	Path string `json:",omitempty"`
+	//Expires cookie expire time.
+	//Zero value means session cookie.
+	Expires time.Time `json:",omitempty"`
+	//Secure whether cookie should only be sent over https.
+	Secure bool `json:",omitempty"`
+	//HttpOnly whether cookie is http only.
+	HttpOnly bool `json:",omitempty"`
+}
+
+func (e *CookieJarEntry) key() string {
+	u, err := url.Parse(e.URL)
+	host := ""
+	if err == nil {
+		host = u.Hostname()
+	}
+	return strings.Join([]string{host, strings.ToLower(e.Domain), e.Path, e.Name}, "|")
+}
+
+func (e *CookieJarEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+//Cookie convert entry to http cookie.
+func (e *CookieJarEntry) Cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   e.Domain,
+		Path:     e.Path,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+	}
+}
+
+//CookieJar cookie jar which records cookies so that they can be listed and persisted.
+//Cookies are managed by net/http/cookiejar.
+type CookieJar struct {
+	//Path file path where cookies persisted.
+	//Cookies will not be persisted if empty.
+	Path    string
+	locker  sync.Mutex
+	jar     *cookiejar.Jar
+	entries map[string]*CookieJarEntry
+	order   []string
+}
+
+//NewCookieJar create new in-memory cookie jar.
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(nil)
+	return &CookieJar{
+		jar:     jar,
+		entries: map[string]*CookieJarEntry{},
+	}
+}
+
+//NewFileCookieJar create new cookie jar persisted in given file.
+//Cookies will be loaded from file if file exists,and saved to file every time cookies changed.
+//Session cookies will be persisted too.
+//Return cookie jar created and any error if raised.
+func NewFileCookieJar(path string) (*CookieJar, error) {
+	j := NewCookieJar()
+	j.Path = path
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return nil, err
+	}
+	var entries []*CookieJarEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if e.expired(now) {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		j.jar.SetCookies(u, []*http.Cookie{e.Cookie()})
+		j.record(e)
+	}
+	return j, nil
+}
+
+func (j *CookieJar) record(e *CookieJarEntry) {
+	key := e.key()
+	if _, ok := j.entries[key]; !ok {
+		j.order = append(j.order, key)
+	}
+	j.entries[key] = e
+}
+
+func (j *CookieJar) remove(key string) {
+	if _, ok := j.entries[key]; !ok {
+		return
+	}
+	delete(j.entries, key)
+	for k, v := range j.order {
+		if v == key {
+			j.order = append(j.order[:k], j.order[k+1:]...)
+			break
+		}
+	}
+}
+
+//SetCookies handle the receipt of the cookies in a reply for the given url.
+//Cookies will be saved to file if Path is not empty.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	now := time.Now()
+	j.locker.Lock()
+	defer j.locker.Unlock()
+	for _, c := range cookies {
+		e := &CookieJarEntry{
+			URL:      u.Scheme + "://" + u.Host + u.Path,
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if c.MaxAge > 0 {
+			e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		if c.MaxAge < 0 || e.expired(now) {
+			j.remove(e.key())
+			continue
+		}
+		j.record(e)
+	}
+	if j.Path != "" {
+		j.save()
+	}
+}
+
+//Cookies return the cookies to send in a request for the given url.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+//Entries return unexpired cookies recorded in jar,sorted by url and name.
+func (j *CookieJar) Entries() []*CookieJarEntry {
+	j.locker.Lock()
+	defer j.locker.Unlock()
+	return j.unexpired()
+}
+
+func (j *CookieJar) unexpired() []*CookieJarEntry {
+	now := time.Now()
+	result := []*CookieJarEntry{}
+	for _, key := range j.order {
+		e := j.entries[key]
+		if e.expired(now) {
+			continue
+		}
+		entry := *e
+		result = append(result, &entry)
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		if result[a].URL != result[b].URL {
+			return result[a].URL < result[b].URL
+		}
+		return result[a].Name < result[b].Name
+	})
+	return result
+}
+
+//Save save unexpired cookies to file.
+//Return any error if raised.
+func (j *CookieJar) Save() error {
+	j.locker.Lock()
+	defer j.locker.Unlock()
+	return j.save()
+}
+
+func (j *CookieJar) save() error {
+	data, err := json.MarshalIndent(j.unexpired(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(j.Path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), j.Path)
+}
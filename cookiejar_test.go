@@ -0,0 +1,77 @@
+package fetcher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCookieJar(t *testing.T) {
+	j := NewCookieJar()
+	u, _ := url.Parse("http://www.example.com/path")
+	j.SetCookies(u, []*http.Cookie{
+		&http.Cookie{Name: "session", Value: "s1"},
+		&http.Cookie{Name: "token", Value: "t1", MaxAge: 3600},
+		&http.Cookie{Name: "old", Value: "o1", Expires: time.Now().Add(-time.Hour)},
+	})
+	cookies := j.Cookies(u)
+	if len(cookies) != 2 {
+		t.Fatal(cookies)
+	}
+	entries := j.Entries()
+	if len(entries) != 2 || entries[0].Name != "session" || entries[1].Name != "token" || entries[1].Expires.IsZero() {
+		t.Fatal(entries)
+	}
+	j.SetCookies(u, []*http.Cookie{&http.Cookie{Name: "session", Value: "s2"}})
+	entries = j.Entries()
+	if len(entries) != 2 || entries[0].Value != "s2" {
+		t.Fatal(entries)
+	}
+	j.SetCookies(u, []*http.Cookie{&http.Cookie{Name: "token", MaxAge: -1}})
+	entries = j.Entries()
+	if len(entries) != 1 || len(j.Cookies(u)) != 1 {
+		t.Fatal(entries)
+	}
+}
+
+func TestFileCookieJar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchercookie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cookies.json")
+	j, err := NewFileCookieJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://www.example.com/")
+	j.SetCookies(u, []*http.Cookie{
+		&http.Cookie{Name: "session", Value: "s1", Domain: "example.com"},
+		&http.Cookie{Name: "token", Value: "t1", MaxAge: 3600},
+	})
+	loaded, err := NewFileCookieJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Entries()) != 2 {
+		t.Fatal(loaded.Entries())
+	}
+	sub, _ := url.Parse("http://api.example.com/")
+	cookies := loaded.Cookies(sub)
+	if len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Fatal(cookies)
+	}
+	err = ioutil.WriteFile(path, []byte("invalid"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewFileCookieJar(path)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,11 @@
 package fetcher
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"sync"
@@ -24,12 +29,58 @@ var DefaultIdleConnTimeout = 120 * time.Second
 //DefaultTLSHandshakeTimeout default client tls handshake time out.
 var DefaultTLSHandshakeTimeout = 30 * time.Second
 
+//DefaultMaxIdleConnsPerHost default client max idle conns per host.
+var DefaultMaxIdleConnsPerHost = 20
+
+//DefaultMaxConnsPerHost default client max conns per host.
+//0 means no limit.
+var DefaultMaxConnsPerHost = 0
+
+//DefaultDialTimeout default client dial timeout.
+var DefaultDialTimeout = 30 * time.Second
+
+//DefaultKeepAlive default client tcp keep-alive period.
+var DefaultKeepAlive = 30 * time.Second
+
+//DefaultResponseHeaderTimeout default client response header timeout.
+//0 means no timeout.
+var DefaultResponseHeaderTimeout time.Duration
+
+//DefaultExpectContinueTimeout default client expect continue timeout.
+var DefaultExpectContinueTimeout = 1 * time.Second
+
+//DefaultForceAttemptHTTP2 default value of whether client should attempt HTTP/2.
+var DefaultForceAttemptHTTP2 = true
+
+//DefaultReadBufferSize default client read buffer size.
+//0 means go default.
+var DefaultReadBufferSize = 0
+
+//DefaultWriteBufferSize default client write buffer size.
+//0 means go default.
+var DefaultWriteBufferSize = 0
+
 //Client http client config struct
 //Value should not changed after first "DO" call.
 type Client struct {
 	//TimeoutInSecond timeout in secound
 	//Default value is 120.
 	TimeoutInSecond int64
+	//Retry retry config.
+	//Requests will not be retried if nil.
+	Retry *RetryConfig
+	//CircuitBreaker circuit breaker config.
+	//Circuits are tracked per request host.
+	//Circuit breaker will not be used if nil.
+	CircuitBreaker *CircuitBreakerConfig
+	//RateLimit rate limit in "count/unit" format,e.g. "10/s","600/m".
+	//Requests will not be limited if set to empty string.
+	RateLimit string
+	//RateLimitBurst rate limit burst size.
+	//Default value is rate limit count per second rounded up.
+	RateLimitBurst int
+	//RateLimitPerHost whether rate limit is applied per request host.
+	RateLimitPerHost bool
 	//MaxIdleConns max idel conns.
 	//Default value is 20
 	MaxIdleConns int
@@ -39,23 +90,237 @@ type Client struct {
 	//TLSHandshakeTimeoutInSecond tls handshake timeout in secound.
 	//default value is 30.
 	TLSHandshakeTimeoutInSecond int64
+	//MaxIdleConnsPerHost max idle conns per host.
+	//Default value is 20.
+	MaxIdleConnsPerHost int
+	//MaxConnsPerHost max conns per host.
+	//Default value is 0,which means no limit.
+	MaxConnsPerHost int
+	//DialTimeoutInSecond dial timeout in second.
+	//Default value is 30.
+	DialTimeoutInSecond int64
+	//KeepAliveInSecond tcp keep-alive period in second.
+	//Default value is 30.
+	KeepAliveInSecond int64
+	//ResponseHeaderTimeoutInSecond response header timeout in second.
+	//Default value is 0,which means no timeout.
+	ResponseHeaderTimeoutInSecond int64
+	//ExpectContinueTimeoutInSecond expect continue timeout in second.
+	//Default value is 1.
+	ExpectContinueTimeoutInSecond int64
+	//DisableCompression whether transparent gzip compression should be disabled.
+	DisableCompression bool
+	//DisableKeepAlives whether http keep-alives should be disabled.
+	DisableKeepAlives bool
+	//DisableHTTP2 whether HTTP/2 should be disabled.
+	//HTTP/2 will be attempted if DisableHTTP2 is false and DefaultForceAttemptHTTP2 is true,which is the default value.
+	DisableHTTP2 bool
+	//ReadBufferSize read buffer size in bytes.
+	//Default value is 0,which means go default.
+	ReadBufferSize int
+	//WriteBufferSize write buffer size in bytes.
+	//Default value is 0,which means go default.
+	WriteBufferSize int
 	//Proxy proxy url.
-	//If set to empty string,clients will not use proxy.
+	//Supported schemes are "http","https","socks5" and "socks5h".
+	//If set to empty string,proxy from environment will be used.
 	//Default value is empty string.
-	Proxy  string
-	locker sync.Mutex
-	doer   Doer
+	Proxy string
+	//ProxyUsername proxy username.
+	//Username in Proxy url will be overwritten if not empty.
+	ProxyUsername string
+	//ProxyPassword proxy password.
+	ProxyPassword string
+	//NoProxy hosts which should be connected directly without proxy.
+	//Item could be "*",domain,ip or CIDR.
+	NoProxy []string
+	//MaxRedirects max redirects to follow.
+	//Default value is 0,which means DefaultMaxRedirects.
+	MaxRedirects int
+	//DisableRedirect whether redirects should not be followed.
+	//Redirect response will be returned as final response if true.
+	DisableRedirect bool
+	//RedirectAllowedHosts hosts which redirects are allowed to.
+	//Item could be "*",domain,ip or CIDR.
+	//Redirects to all hosts are allowed if empty.
+	RedirectAllowedHosts []string
+	//RedirectRewriteToGet whether 307 and 308 redirects should be followed as GET without body.
+	RedirectRewriteToGet bool
+	//EnableCookieJar whether in-memory cookie jar should be used.
+	EnableCookieJar bool
+	//CookieJarFile file path where cookies persisted.
+	//Cookie jar will be enabled if not empty.
+	CookieJarFile string
+	//UnixSocket path of unix domain socket which all connections dial to regardless of url host.
+	//Proxy will not be used if set.
+	UnixSocket string
+	//TLSCAFile path of PEM encoded CA bundle used to verify servers.
+	//CAs will be appended to system cert pool.
+	TLSCAFile string
+	//TLSCA PEM encoded CA bundle used to verify servers.
+	//CAs will be appended to system cert pool.
+	TLSCA string
+	//TLSCertFile path of PEM encoded client certificate.
+	//Should be setted with TLSKeyFile.
+	TLSCertFile string
+	//TLSKeyFile path of PEM encoded client private key.
+	//Should be setted with TLSCertFile.
+	TLSKeyFile string
+	//TLSMinVersion min tls version,one of "1.0","1.1","1.2","1.3".
+	//Default value is empty string,which means go default.
+	TLSMinVersion string
+	//TLSServerName server name used to verify server certificate instead of request host.
+	TLSServerName string
+	//TLSInsecureSkipVerify whether server certificate verification should be skipped.
+	//INSECURE,should only be used in testing.
+	TLSInsecureSkipVerify bool
+	locker                sync.Mutex
+	doer                  Doer
+	middlewares           []DoerMiddleware
+	breaker               *CircuitBreaker
+	breakerConfig         CircuitBreakerConfig
+	limiter               *RateLimiter
+	limiterKey            string
+	dialContext           DialContextFunc
+	jarLocker             sync.Mutex
+	jar                   *CookieJar
+	poolLocker            sync.Mutex
+	transports            map[string]*clientTransport
+	transportOwner        *Client
 }
 
 //Clone clone a new client.
+//Middlewares and dial func will be copied,cookie jar,circuit breaker,rate limiter and created doer will not.
 func (c *Client) Clone() *Client {
-	return &Client{
-		TimeoutInSecond:             c.TimeoutInSecond,
-		MaxIdleConns:                c.MaxIdleConns,
-		IdleConnTimeoutInSecond:     c.IdleConnTimeoutInSecond,
-		TLSHandshakeTimeoutInSecond: c.TLSHandshakeTimeoutInSecond,
-		Proxy:                       c.Proxy,
+	cloned := &Client{}
+	cloned.copyConfig(c)
+	cloned.middlewares = c.Middlewares()
+	cloned.dialContext = c.DialContext()
+	return cloned
+}
+
+func (c *Client) copyConfig(src *Client) {
+	c.TimeoutInSecond = src.TimeoutInSecond
+	c.Retry = src.Retry.Clone()
+	c.CircuitBreaker = src.CircuitBreaker.Clone()
+	c.RateLimit = src.RateLimit
+	c.RateLimitBurst = src.RateLimitBurst
+	c.RateLimitPerHost = src.RateLimitPerHost
+	c.MaxIdleConns = src.MaxIdleConns
+	c.IdleConnTimeoutInSecond = src.IdleConnTimeoutInSecond
+	c.TLSHandshakeTimeoutInSecond = src.TLSHandshakeTimeoutInSecond
+	c.MaxIdleConnsPerHost = src.MaxIdleConnsPerHost
+	c.MaxConnsPerHost = src.MaxConnsPerHost
+	c.DialTimeoutInSecond = src.DialTimeoutInSecond
+	c.KeepAliveInSecond = src.KeepAliveInSecond
+	c.ResponseHeaderTimeoutInSecond = src.ResponseHeaderTimeoutInSecond
+	c.ExpectContinueTimeoutInSecond = src.ExpectContinueTimeoutInSecond
+	c.DisableCompression = src.DisableCompression
+	c.DisableKeepAlives = src.DisableKeepAlives
+	c.DisableHTTP2 = src.DisableHTTP2
+	c.ReadBufferSize = src.ReadBufferSize
+	c.WriteBufferSize = src.WriteBufferSize
+	c.Proxy = src.Proxy
+	c.ProxyUsername = src.ProxyUsername
+	c.ProxyPassword = src.ProxyPassword
+	c.NoProxy = append([]string(nil), src.NoProxy...)
+	c.MaxRedirects = src.MaxRedirects
+	c.DisableRedirect = src.DisableRedirect
+	c.RedirectAllowedHosts = append([]string(nil), src.RedirectAllowedHosts...)
+	c.RedirectRewriteToGet = src.RedirectRewriteToGet
+	c.EnableCookieJar = src.EnableCookieJar
+	c.CookieJarFile = src.CookieJarFile
+	c.UnixSocket = src.UnixSocket
+	c.TLSCAFile = src.TLSCAFile
+	c.TLSCA = src.TLSCA
+	c.TLSCertFile = src.TLSCertFile
+	c.TLSKeyFile = src.TLSKeyFile
+	c.TLSMinVersion = src.TLSMinVersion
+	c.TLSServerName = src.TLSServerName
+	c.TLSInsecureSkipVerify = src.TLSInsecureSkipVerify
+}
+
+//Use register doer middlewares to client.
+//Middlewares will be applied in order when doer created,first middleware will be the outermost one.
+//Middlewares should be registered before first "DO" call.
+func (c *Client) Use(mws ...DoerMiddleware) *Client {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.middlewares = append(c.middlewares, mws...)
+	return c
+}
+
+//Middlewares return registered doer middlewares.
+func (c *Client) Middlewares() []DoerMiddleware {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	mws := make([]DoerMiddleware, len(c.middlewares))
+	copy(mws, c.middlewares)
+	return mws
+}
+
+//SetDialContext set dial func used by transport to create connections.
+//Dial timeout and keep alive settings will be ignored if dial func set.
+//Dial func should be set before first "DO" call.
+func (c *Client) SetDialContext(fn DialContextFunc) *Client {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.dialContext = fn
+	return c
+}
+
+//DialContext return dial func setted by SetDialContext.
+func (c *Client) DialContext() DialContextFunc {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.dialContext
+}
+
+//CreateRedirectPolicy create redirect policy by client config.
+//Return redirect policy and any error if raised.
+func (c *Client) CreateRedirectPolicy() (*RedirectPolicy, error) {
+	err := validateHostPatterns(c.RedirectAllowedHosts)
+	if err != nil {
+		return nil, err
 	}
+	return &RedirectPolicy{
+		MaxRedirects: c.MaxRedirects,
+		Disable:      c.DisableRedirect,
+		AllowedHosts: append([]string(nil), c.RedirectAllowedHosts...),
+		RewriteToGet: c.RedirectRewriteToGet,
+	}, nil
+}
+
+//CookieJar return cookie jar used by client.
+//Cookie jar will be created at first call and shared by all doers created by client.
+//Return nil if neither EnableCookieJar nor CookieJarFile setted.
+//Return cookie jar and any error if raised.
+func (c *Client) CookieJar() (*CookieJar, error) {
+	c.jarLocker.Lock()
+	defer c.jarLocker.Unlock()
+	if c.jar != nil {
+		return c.jar, nil
+	}
+	if c.CookieJarFile != "" {
+		jar, err := NewFileCookieJar(c.CookieJarFile)
+		if err != nil {
+			return nil, err
+		}
+		c.jar = jar
+	} else if c.EnableCookieJar {
+		c.jar = NewCookieJar()
+	}
+	return c.jar, nil
+}
+
+//Cookies return cookies in client cookie jar which will be sent to given url.
+//Return nil if cookie jar not enabled or can not be created.
+func (c *Client) Cookies(u *url.URL) []*http.Cookie {
+	jar, err := c.CookieJar()
+	if err != nil || jar == nil {
+		return nil
+	}
+	return jar.Cookies(u)
 }
 
 //Exec exec command to modify fetcher.
@@ -66,22 +331,89 @@ func (c *Client) Exec(f *Fetcher) error {
 }
 
 //CreateDoer create doer.
+//Circuit breaker and rate limiter are shared by all doers created by client.
 //Return doer createrd and any error if raised.
 func (c *Client) CreateDoer() (Doer, error) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	mws := make([]DoerMiddleware, len(c.middlewares))
+	copy(mws, c.middlewares)
+	d, _, err := c.createDoer(mws, c.dialContext)
+	return d, err
+}
+
+//circuitBreaker return circuit breaker shared by doers created by client.
+//Circuit breaker will be recreated if config changed.
+//Client locker should be held.
+func (c *Client) circuitBreaker() *CircuitBreaker {
+	if c.breaker == nil || c.breakerConfig != *c.CircuitBreaker {
+		c.breaker = c.CircuitBreaker.CreateCircuitBreaker()
+		c.breakerConfig = *c.CircuitBreaker
+	}
+	return c.breaker
+}
+
+//rateLimiter return rate limiter shared by doers created by client.
+//Rate limiter will be recreated if config changed.
+//Client locker should be held.
+//Return rate limiter and any error if raised.
+func (c *Client) rateLimiter() (*RateLimiter, error) {
+	key := fmt.Sprintf("%s|%d|%t", c.RateLimit, c.RateLimitBurst, c.RateLimitPerHost)
+	if c.limiter != nil && c.limiterKey == key {
+		return c.limiter, nil
+	}
+	limiter, err := CreateRateLimiter(c.RateLimit, c.RateLimitBurst, c.RateLimitPerHost)
+	if err != nil {
+		return nil, err
+	}
+	c.limiter = limiter
+	c.limiterKey = key
+	return limiter, nil
+}
+
+//createDoer create doer by client config.
+//Client locker should be held.
+func (c *Client) createDoer(mws []DoerMiddleware, dial DialContextFunc) (Doer, *http.Transport, error) {
 	var timeout time.Duration
 	if c.TimeoutInSecond > 0 {
 		timeout = time.Duration(c.TimeoutInSecond) * time.Second
 	} else {
 		timeout = DefaultTimeout
 	}
-	transport := c.getTransport()
-	transport.Proxy = URLToProxy(c.Proxy)
+	transport, err := c.acquireTransport(dial)
+	if err != nil {
+		return nil, nil, err
+	}
 	client := http.Client{
 		Timeout:   timeout,
 		Transport: transport,
 	}
-
-	return &client, nil
+	redirect, err := c.CreateRedirectPolicy()
+	if err != nil {
+		return nil, nil, err
+	}
+	client.CheckRedirect = redirect.CheckRedirect
+	jar, err := c.CookieJar()
+	if err != nil {
+		return nil, nil, err
+	}
+	if jar != nil {
+		client.Jar = jar
+	}
+	if c.RateLimit != "" {
+		limiter, err := c.rateLimiter()
+		if err != nil {
+			return nil, nil, err
+		}
+		mws = append([]DoerMiddleware{limiter.Wrap}, mws...)
+	}
+	if c.Retry != nil {
+		mws = append([]DoerMiddleware{RetryMiddleware(c.Retry.CreatePolicy())}, mws...)
+	}
+	if c.CircuitBreaker != nil {
+		mws = append([]DoerMiddleware{c.circuitBreaker().Wrap}, mws...)
+	}
+	return ChainDoer(&client, mws...), transport, nil
 }
 
 func (c *Client) getDoer() (Doer, error) {
@@ -90,7 +422,9 @@ func (c *Client) getDoer() (Doer, error) {
 	if c.doer != nil {
 		return c.doer, nil
 	}
-	d, err := c.CreateDoer()
+	mws := make([]DoerMiddleware, len(c.middlewares))
+	copy(mws, c.middlewares)
+	d, _, err := c.createDoer(mws, c.dialContext)
 	if err != nil {
 		return nil, err
 	}
@@ -115,8 +449,77 @@ func (c *Client) SelfCheck() error {
 	return err
 }
 
+//Reset drop created doer so that doer will be created by current config at next "DO" call.
+//Transports held by client will be released,in-flight requests will finish on old transport.
+func (c *Client) Reset() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.doer = nil
+	c.releaseTransports(nil)
+}
+
+//Reload replace client config with given config and swap doer atomically.
+//Middlewares and dial func of client will be kept.
+//Cookie jar will be kept unless cookie jar config changed.
+//Client config will not be changed if new doer can not be created.
+//Old transports will be released,in-flight requests will finish on old transport.
+//Return any error if raised.
+func (c *Client) Reload(cfg *Client) error {
+	newcfg := &Client{}
+	newcfg.copyConfig(cfg)
+	c.locker.Lock()
+	oldcfg := &Client{}
+	oldcfg.copyConfig(c)
+	oldbreaker, oldbreakerConfig := c.breaker, c.breakerConfig
+	oldlimiter, oldlimiterKey := c.limiter, c.limiterKey
+	c.jarLocker.Lock()
+	c.copyConfig(newcfg)
+	oldjar := c.jar
+	if c.EnableCookieJar != oldcfg.EnableCookieJar || c.CookieJarFile != oldcfg.CookieJarFile {
+		c.jar = nil
+	}
+	c.jarLocker.Unlock()
+	mws := make([]DoerMiddleware, len(c.middlewares))
+	copy(mws, c.middlewares)
+	d, t, err := c.createDoer(mws, c.dialContext)
+	if err != nil {
+		c.jarLocker.Lock()
+		c.copyConfig(oldcfg)
+		c.jar = oldjar
+		c.jarLocker.Unlock()
+		c.breaker, c.breakerConfig = oldbreaker, oldbreakerConfig
+		c.limiter, c.limiterKey = oldlimiter, oldlimiterKey
+		c.locker.Unlock()
+		return err
+	}
+	c.doer = d
+	c.releaseTransports(t)
+	c.locker.Unlock()
+	return nil
+}
+
+//CloseIdleConnections close idle connections of transports held by client.
+//Connections in use will not be interrupted.
+//Transports shared with other clients will be affected too.
+func (c *Client) CloseIdleConnections() {
+	for _, t := range c.heldTransports() {
+		t.CloseIdleConnections()
+	}
+}
+
+//Close release transports held by client.
+//Shared transport will be closed when released by all clients.
+//Client could still be used after closed,a new doer will be created at next "DO" call.
+//Return any error if raised.
+func (c *Client) Close() error {
+	c.Reset()
+	return nil
+}
+
 //URLToProxy Convert url to fixed proxy.
-//Return proxy created and any error if raised.
+//Proxy from environment will be returned if index is empty or invalid.
+//Client uses ParseProxy instead,which reports invalid proxy as error.
+//Return proxy created.
 func URLToProxy(index string) func(*http.Request) (*url.URL, error) {
 	if index == "" {
 		return http.ProxyFromEnvironment
@@ -128,7 +531,14 @@ func URLToProxy(index string) func(*http.Request) (*url.URL, error) {
 	return http.ProxyURL(url)
 }
 
-func (c *Client) getTransport() *http.Transport {
+func (c *Client) attemptHTTP2() bool {
+	if c.DisableHTTP2 {
+		return false
+	}
+	return DefaultForceAttemptHTTP2
+}
+
+func (c *Client) getTransport(dial DialContextFunc) (*http.Transport, error) {
 	var maxIdleCoons = c.MaxIdleConns
 	if maxIdleCoons == 0 {
 		maxIdleCoons = DefaultMaxIdleConns
@@ -146,12 +556,74 @@ func (c *Client) getTransport() *http.Transport {
 		tlsHandshakeTimeout = time.Duration(c.TLSHandshakeTimeoutInSecond) * time.Second
 	}
 
-	return &http.Transport{
-		Proxy:               URLToProxy(c.Proxy),
-		MaxIdleConns:        maxIdleCoons,
-		IdleConnTimeout:     idleConnTimeout,
-		TLSHandshakeTimeout: tlsHandshakeTimeout,
+	var maxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	var maxConnsPerHost = c.MaxConnsPerHost
+	if maxConnsPerHost == 0 {
+		maxConnsPerHost = DefaultMaxConnsPerHost
+	}
+	var readBufferSize = c.ReadBufferSize
+	if readBufferSize == 0 {
+		readBufferSize = DefaultReadBufferSize
+	}
+	var writeBufferSize = c.WriteBufferSize
+	if writeBufferSize == 0 {
+		writeBufferSize = DefaultWriteBufferSize
+	}
+	dialer := &net.Dialer{
+		Timeout:   secondsOrDefault(c.DialTimeoutInSecond, DefaultDialTimeout),
+		KeepAlive: secondsOrDefault(c.KeepAliveInSecond, DefaultKeepAlive),
+	}
+	tlsConfig, err := c.CreateTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		MaxIdleConns:          maxIdleCoons,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		TLSClientConfig:       tlsConfig,
+		ResponseHeaderTimeout: secondsOrDefault(c.ResponseHeaderTimeoutInSecond, DefaultResponseHeaderTimeout),
+		ExpectContinueTimeout: secondsOrDefault(c.ExpectContinueTimeoutInSecond, DefaultExpectContinueTimeout),
+		DisableCompression:    c.DisableCompression,
+		DisableKeepAlives:     c.DisableKeepAlives,
+		ForceAttemptHTTP2:     c.attemptHTTP2(),
+		ReadBufferSize:        readBufferSize,
+		WriteBufferSize:       writeBufferSize,
+	}
+	if !transport.ForceAttemptHTTP2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	if c.UnixSocket != "" {
+		if c.Proxy != "" {
+			return nil, errors.New("fetcher:unix socket can not be used with proxy")
+		}
+		socket := c.UnixSocket
+		forward := dial
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return forward(ctx, "unix", socket)
+		}
+		return transport, nil
+	}
+	err = c.applyProxy(transport, dial)
+	if err != nil {
+		return nil, err
+	}
+	return transport, nil
+}
+
+func secondsOrDefault(seconds int64, d time.Duration) time.Duration {
+	if seconds <= 0 {
+		return d
 	}
+	return time.Duration(seconds) * time.Second
 }
 
 //DoerFactory doer factory
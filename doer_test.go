@@ -3,9 +3,15 @@ package fetcher
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -32,14 +38,34 @@ func TestDefaultClient(t *testing.T) {
 		transport.IdleConnTimeout != DefaultIdleConnTimeout {
 		t.Fatal(transport)
 	}
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost ||
+		transport.MaxConnsPerHost != DefaultMaxConnsPerHost ||
+		transport.ResponseHeaderTimeout != DefaultResponseHeaderTimeout ||
+		transport.ExpectContinueTimeout != DefaultExpectContinueTimeout ||
+		transport.ForceAttemptHTTP2 != DefaultForceAttemptHTTP2 ||
+		transport.DisableCompression ||
+		transport.DisableKeepAlives ||
+		transport.DialContext == nil {
+		t.Fatal(transport)
+	}
 }
 
 func TestClientConfig(t *testing.T) {
 	c := &Client{
-		TimeoutInSecond:             15,
-		TLSHandshakeTimeoutInSecond: 16,
-		IdleConnTimeoutInSecond:     17,
-		MaxIdleConns:                18,
+		TimeoutInSecond:               15,
+		TLSHandshakeTimeoutInSecond:   16,
+		IdleConnTimeoutInSecond:       17,
+		MaxIdleConns:                  18,
+		MaxIdleConnsPerHost:           19,
+		MaxConnsPerHost:               20,
+		DialTimeoutInSecond:           21,
+		KeepAliveInSecond:             22,
+		ResponseHeaderTimeoutInSecond: 23,
+		ExpectContinueTimeoutInSecond: 24,
+		DisableCompression:            true,
+		DisableKeepAlives:             true,
+		ReadBufferSize:                25,
+		WriteBufferSize:               26,
 	}
 	d, err := c.CreateDoer()
 	if err != nil {
@@ -61,7 +87,74 @@ func TestClientConfig(t *testing.T) {
 		transport.IdleConnTimeout != 17*time.Second {
 		t.Fatal(transport)
 	}
+	if transport.MaxIdleConnsPerHost != 19 ||
+		transport.MaxConnsPerHost != 20 ||
+		transport.ResponseHeaderTimeout != 23*time.Second ||
+		transport.ExpectContinueTimeout != 24*time.Second ||
+		!transport.DisableCompression ||
+		!transport.DisableKeepAlives ||
+		!transport.ForceAttemptHTTP2 ||
+		transport.ReadBufferSize != 25 ||
+		transport.WriteBufferSize != 26 {
+		t.Fatal(transport)
+	}
+	cloned := c.Clone()
+	if cloned.DialTimeoutInSecond != 21 || cloned.KeepAliveInSecond != 22 || cloned.WriteBufferSize != 26 {
+		t.Fatal(cloned)
+	}
+
+}
 
+func TestClientDisableHTTP2(t *testing.T) {
+	c := &Client{
+		DisableHTTP2: true,
+	}
+	if c.transportKey() == (&Client{}).transportKey() {
+		t.Fatal(c.transportKey())
+	}
+	d, err := c.CreateDoer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := d.(*http.Client).Transport.(*http.Transport)
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Fatal(transport)
+	}
+	cloned := c.Clone()
+	if !cloned.DisableHTTP2 {
+		t.Fatal(cloned)
+	}
+	defaultHTTP2 := DefaultForceAttemptHTTP2
+	DefaultForceAttemptHTTP2 = false
+	key := (&Client{}).transportKey()
+	DefaultForceAttemptHTTP2 = defaultHTTP2
+	if key != c.transportKey() {
+		t.Fatal(key)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	for _, disabled := range []bool{true, false} {
+		c := &Client{
+			TLSInsecureSkipVerify: true,
+			DisableHTTP2:          disabled,
+		}
+		resp, err := NewPreset().With(c, URL(server.URL)).Fetch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		proto, err := resp.BodyContent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if disabled != (string(proto) == "HTTP/1.1") {
+			t.Fatal(disabled, string(proto))
+		}
+		c.Close()
+	}
 }
 
 func TestProxy(t *testing.T) {
@@ -208,3 +301,344 @@ func TestClientClone(t *testing.T) {
 		t.Fatal(cloned)
 	}
 }
+
+func TestClientUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetcherunix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "test.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.Path))
+	}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+	client := &Client{UnixSocket: socket}
+	preset := NewPreset().With(client, URL("http://docker/v1.41/version"))
+	resp, err := preset.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := resp.BodyContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "docker/v1.41/version" {
+		t.Fatal(string(body))
+	}
+	client = &Client{UnixSocket: socket, Proxy: "http://127.0.0.1:8080"}
+	if client.SelfCheck() == nil {
+		t.Fatal()
+	}
+}
+
+func TestClientSetDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(EchoAction))
+	defer server.Close()
+	var dialed []string
+	client := &Client{}
+	c := client.SetDialContext(func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	})
+	if c != client || client.DialContext() == nil {
+		t.Fatal(c)
+	}
+	if client.Clone().DialContext() == nil {
+		t.Fatal()
+	}
+	resp, err := NewPreset().With(client, URL("http://sidecar.local/")).Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatal(resp.StatusCode)
+	}
+	if len(dialed) != 1 || dialed[0] != "sidecar.local:80" {
+		t.Fatal(dialed)
+	}
+}
+
+func TestClientCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			return
+		}
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte(c.Value + r.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+	client := &Client{}
+	jar, err := client.CookieJar()
+	if jar != nil || err != nil {
+		t.Fatal(jar, err)
+	}
+	client = &Client{EnableCookieJar: true}
+	preset := NewPreset().With(client, URL(server.URL))
+	resp, err := preset.With(PathJoin("login")).Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = preset.With(PathJoin("profile"), Cookie("k", "v")).Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := resp.BodyContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "s1k=v; session=s1" {
+		t.Fatal(string(body))
+	}
+	u, _ := url.Parse(server.URL)
+	cookies := client.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Fatal(cookies)
+	}
+	d, err := client.CreateDoer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, _ = client.CookieJar()
+	if d.(*http.Client).Jar != jar {
+		t.Fatal(d)
+	}
+	if client.Clone().Cookies(u) != nil {
+		t.Fatal()
+	}
+}
+
+func TestClientCookieJarFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchercookie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cookies.json")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+	}))
+	defer server.Close()
+	resp, err := NewPreset().With(&Client{CookieJarFile: path}, URL(server.URL)).Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	u, _ := url.Parse(server.URL)
+	cookies := (&Client{CookieJarFile: path}).Cookies(u)
+	if len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Fatal(cookies)
+	}
+	err = ioutil.WriteFile(path, []byte("invalid"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (&Client{CookieJarFile: path}).SelfCheck() == nil {
+		t.Fatal()
+	}
+}
+
+func TestClientReload(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.Write([]byte("direct"))
+	}))
+	defer server.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+	client := &Client{TimeoutInSecond: 5, EnableCookieJar: true}
+	jar, _ := client.CookieJar()
+	fetch := func(path string) (string, error) {
+		resp, err := NewPreset().With(client, URL(server.URL+path)).Fetch()
+		if err != nil {
+			return "", err
+		}
+		body, err := resp.BodyContent()
+		return string(body), err
+	}
+	body, err := fetch("/")
+	if err != nil || body != "direct" {
+		t.Fatal(body, err)
+	}
+	result := make(chan string)
+	go func() {
+		body, err := fetch("/slow")
+		if err != nil {
+			body = err.Error()
+		}
+		result <- body
+	}()
+	<-started
+	err = client.Reload(&Client{TimeoutInSecond: 10, Proxy: proxy.URL, EnableCookieJar: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.TimeoutInSecond != 10 || client.Proxy != proxy.URL {
+		t.Fatal(client)
+	}
+	if j, _ := client.CookieJar(); j != jar {
+		t.Fatal(j)
+	}
+	body, err = fetch("/")
+	if err != nil || body != "proxied" {
+		t.Fatal(body, err)
+	}
+	close(release)
+	if body := <-result; body != "direct" {
+		t.Fatal(body)
+	}
+	err = client.Reload(&Client{Proxy: "ftp://invalid"})
+	if err == nil {
+		t.Fatal(err)
+	}
+	if client.Proxy != proxy.URL || !client.EnableCookieJar {
+		t.Fatal(client)
+	}
+	if j, _ := client.CookieJar(); j != jar {
+		t.Fatal(j)
+	}
+	body, err = fetch("/")
+	if err != nil || body != "proxied" {
+		t.Fatal(body, err)
+	}
+	err = client.Reload(&Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := client.CookieJar(); j != nil {
+		t.Fatal(j)
+	}
+	body, err = fetch("/")
+	if err != nil || body != "direct" {
+		t.Fatal(body, err)
+	}
+}
+
+func TestServerPresetReload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	s := &Server{ServerInfo: ServerInfo{URL: server.URL}}
+	defer s.Close()
+	preset, err := s.CreatePreset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := preset.With(PathSuffix("/redirect")).FetchAndParse(AsUselessBody)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal(resp, err)
+	}
+	err = s.Client.Reload(&Client{DisableRedirect: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = preset.With(PathSuffix("/redirect")).FetchAndParse(AsUselessBody)
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatal(resp, err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 10; i++ {
+		wg.Add(4)
+		go func(i int) {
+			defer wg.Done()
+			errs <- s.Client.Reload(&Client{DisableRedirect: i%2 == 0, EnableCookieJar: i%3 == 0, RateLimit: "1000/s"})
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := preset.FetchAndParse(Should200(nil))
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := s.Client.CreateDoer()
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := s.CreatePreset()
+			s.Client.Cookies(&url.URL{Scheme: "http", Host: "127.0.0.1"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = (&Server{Client: Client{Proxy: "ftp://invalid"}}).CreatePreset()
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientReset(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer server.Close()
+	client := &Client{}
+	client.CloseIdleConnections()
+	fetch := func() string {
+		resp, err := NewPreset().With(client, URL(server.URL)).Fetch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := resp.BodyContent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	if body := fetch(); body != "direct" {
+		t.Fatal(body)
+	}
+	client.Proxy = proxy.URL
+	if body := fetch(); body != "direct" {
+		t.Fatal(body)
+	}
+	client.Reset()
+	if body := fetch(); body != "proxied" {
+		t.Fatal(body)
+	}
+	client.CloseIdleConnections()
+	err := client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Proxy = ""
+	if body := fetch(); body != "direct" {
+		t.Fatal(body)
+	}
+}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,16 +13,27 @@ import (
 type Fetcher struct {
 	//URL http url used to create http request
 	URL *url.URL
+	//URLTemplate RFC 6570 uri template expanded with TemplateVars when http request created.
+	//URL will be applied on expanded url if template is not empty.
+	URLTemplate string
+	//TemplateVars variables used to expand URLTemplate.
+	TemplateVars map[string]interface{}
 	//Header http header used to create http request
 	Header http.Header
 	//Method http method used to create http request
 	Method string
 	//Body request body
 	Body io.Reader
+	//BodySource request body source which can be opened repeatedly.
+	//Body source will be used if Body is nil.
+	BodySource BodySource
 	//Builders request builder which should called in order after http request created.
 	Builders []func(*http.Request) error
 	//Doer http client by which will do request
 	Doer Doer
+	//Context context used to create http request.
+	//context.Background() will be used if nil.
+	Context context.Context
 }
 
 //AppendBuilder append request builders to fetcher.
@@ -32,15 +44,57 @@ func (f *Fetcher) AppendBuilder(b ...func(*http.Request) error) {
 
 //Raw create raw http request,doer and any error if raised.
 func (f *Fetcher) Raw() (*http.Request, Doer, error) {
-	url := f.URL.String()
-	req, err := http.NewRequest(f.Method, url, f.Body)
+	u := f.URL
+	if f.URLTemplate != "" {
+		var err error
+		u, err = templateURL(f.URLTemplate, f.TemplateVars, f.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	url := u.String()
+	ctx := f.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	body := f.Body
+	var src BodySource
+	if body == nil && f.BodySource != nil {
+		src = f.BodySource
+		rc, err := src.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		body = rc
+	}
+	req, err := http.NewRequestWithContext(ctx, f.Method, url, body)
 	if err != nil {
+		if src != nil {
+			body.(io.Closer).Close()
+		}
 		return nil, nil, err
 	}
-	MergeHeader(req.Header, f.Header)
+	if src != nil {
+		size := src.Size()
+		req.ContentLength = size
+		if size == 0 {
+			req.Body.Close()
+			req.Body = http.NoBody
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			if size == 0 {
+				return http.NoBody, nil
+			}
+			return src.Open()
+		}
+	}
+	MergeHeaderWithMode(req.Header, f.Header, HeaderMergeAppend)
 	for k := range f.Builders {
 		err = f.Builders[k](req)
 		if err != nil {
+			if src != nil {
+				req.Body.Close()
+			}
 			return nil, nil, err
 		}
 	}
@@ -2,6 +2,7 @@ package fetcher
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -51,3 +52,31 @@ func TestFetcher(t *testing.T) {
 		t.Fatal(u, p, ok)
 	}
 }
+
+func TestFetcherContext(t *testing.T) {
+	type ctxkey string
+	ctx := context.WithValue(context.Background(), ctxkey("key"), "value")
+	f := New()
+	f.Context = ctx
+	f.AppendBuilder(func(r *http.Request) error {
+		if r.Context().Value(ctxkey("key")) != "value" {
+			t.Fatal(r)
+		}
+		return nil
+	})
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Context() != ctx {
+		t.Fatal(req)
+	}
+	f = New()
+	req, _, err = f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Context() != context.Background() {
+		t.Fatal(req)
+	}
+}
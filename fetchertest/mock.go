@@ -0,0 +1,282 @@
+//Package fetchertest provides utilities for testing code built on fetcher.
+package fetchertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/herb-go/fetcher"
+)
+
+//ErrUnexpectedRequest error returned when no expectation matches request.
+var ErrUnexpectedRequest = errors.New("fetchertest:unexpected request")
+
+//Expectation request expectation registered on MockDoer.
+type Expectation struct {
+	method   string
+	pattern  string
+	header   http.Header
+	query    map[string]string
+	body     *string
+	times    int
+	status   int
+	rheader  http.Header
+	rbody    []byte
+	err      error
+	requests []*http.Request
+	mock     *MockDoer
+}
+
+//WithHeader require request header with given key and value.
+func (e *Expectation) WithHeader(key string, value string) *Expectation {
+	e.header.Add(key, value)
+	return e
+}
+
+//WithQuery require request query with given name and value.
+func (e *Expectation) WithQuery(name string, value string) *Expectation {
+	e.query[name] = value
+	return e
+}
+
+//WithBody require request body equal to given body.
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body = &body
+	return e
+}
+
+//Times set times expectation should be called.
+//Expectation can be called any times if n less than 0.
+//Default value is 1.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+//AnyTimes allow expectation to be called any times,including zero.
+func (e *Expectation) AnyTimes() *Expectation {
+	return e.Times(-1)
+}
+
+//Respond set response status code and body.
+func (e *Expectation) Respond(status int, body string) *Expectation {
+	e.status = status
+	e.rbody = []byte(body)
+	return e
+}
+
+//RespondJSON set response status code and body as json.
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	e.status = status
+	e.rbody = bs
+	e.rheader.Set("Content-Type", "application/json")
+	return e
+}
+
+//RespondHeader set response header with given key and value.
+func (e *Expectation) RespondHeader(key string, value string) *Expectation {
+	e.rheader.Add(key, value)
+	return e
+}
+
+//RespondError make doer return given error instead of response.
+func (e *Expectation) RespondError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+//Requests return requests matched by expectation.
+//It is safe to call Requests while mock doer is doing requests.
+func (e *Expectation) Requests() []*http.Request {
+	e.mock.locker.Lock()
+	defer e.mock.locker.Unlock()
+	result := make([]*http.Request, len(e.requests))
+	copy(result, e.requests)
+	return result
+}
+
+func (e *Expectation) String() string {
+	return e.method + " " + e.pattern
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times >= 0 && len(e.requests) >= e.times
+}
+
+func matchPattern(pattern string, u string) bool {
+	ps := strings.Split(pattern, "/")
+	us := strings.Split(u, "/")
+	if len(ps) != len(us) {
+		return false
+	}
+	for k := range ps {
+		if strings.HasPrefix(ps[k], "{") && strings.HasSuffix(ps[k], "}") && us[k] != "" {
+			continue
+		}
+		if ps[k] != us[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectation) match(req *http.Request, body []byte) bool {
+	if e.method != "" && e.method != req.Method {
+		return false
+	}
+	target := req.URL.Path
+	if strings.Contains(e.pattern, "://") {
+		u := *req.URL
+		u.RawQuery = ""
+		u.Fragment = ""
+		target = u.String()
+	}
+	if !matchPattern(e.pattern, target) {
+		return false
+	}
+	for k := range e.header {
+		if strings.Join(req.Header.Values(k), ",") != strings.Join(e.header.Values(k), ",") {
+			return false
+		}
+	}
+	q := req.URL.Query()
+	for k, v := range e.query {
+		if q.Get(k) != v {
+			return false
+		}
+	}
+	if e.body != nil && *e.body != string(body) {
+		return false
+	}
+	return true
+}
+
+func (e *Expectation) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.rheader.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.rbody)),
+		ContentLength: int64(len(e.rbody)),
+		Request:       req,
+	}
+}
+
+//MockDoer expectation-based mock doer.
+//Unexpected requests and unmet expectations will be reported to testing.TB.
+type MockDoer struct {
+	tb           testing.TB
+	locker       sync.Mutex
+	expectations []*Expectation
+	requests     []*http.Request
+}
+
+//NewMockDoer create new mock doer with given testing.TB.
+//Expectations will be asserted when test finished.
+func NewMockDoer(tb testing.TB) *MockDoer {
+	m := &MockDoer{
+		tb: tb,
+	}
+	tb.Cleanup(m.AssertExpectations)
+	return m
+}
+
+//Expect register new expectation with given method and path pattern.
+//Pattern segment like "{id}" matches any non-empty segment.
+//Pattern which contains "://" will be matched against full url without query.
+//Expectation responds 200 with empty body by default.
+func (m *MockDoer) Expect(method fetcher.Method, pattern string) *Expectation {
+	e := &Expectation{
+		method:  string(method),
+		pattern: pattern,
+		header:  http.Header{},
+		query:   map[string]string{},
+		times:   1,
+		status:  http.StatusOK,
+		rheader: http.Header{},
+		mock:    m,
+	}
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+//Do match request with registered expectations and return response.
+//Return http response and any error if raised.
+func (m *MockDoer) Do(req *http.Request) (*http.Response, error) {
+	m.tb.Helper()
+	var body []byte
+	if req.Body != nil {
+		bs, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		body = bs
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.requests = append(m.requests, req)
+	for _, e := range m.expectations {
+		if e.exhausted() || !e.match(req, body) {
+			continue
+		}
+		e.requests = append(e.requests, req)
+		if e.err != nil {
+			return nil, e.err
+		}
+		return e.response(req), nil
+	}
+	m.tb.Errorf("fetchertest:unexpected request %s %s", req.Method, req.URL.String())
+	return nil, fmt.Errorf("%w [%s %s]", ErrUnexpectedRequest, req.Method, req.URL.String())
+}
+
+//Exec exec command to modify fetcher doer to mock doer.
+//Return any error if raised.
+func (m *MockDoer) Exec(f *fetcher.Fetcher) error {
+	f.Doer = m
+	return nil
+}
+
+//Middleware doer middleware which replaces any doer with mock doer.
+//Can be registered by Client.Use to mock requests of every preset created by client.
+func (m *MockDoer) Middleware(fetcher.Doer) fetcher.Doer {
+	return m
+}
+
+//Requests return all requests received by mock doer.
+func (m *MockDoer) Requests() []*http.Request {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	result := make([]*http.Request, len(m.requests))
+	copy(result, m.requests)
+	return result
+}
+
+//AssertExpectations report expectations which are not called expected times.
+func (m *MockDoer) AssertExpectations() {
+	m.tb.Helper()
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	for _, e := range m.expectations {
+		if e.times >= 0 && len(e.requests) != e.times {
+			m.tb.Errorf("fetchertest:expectation %s called %d times,expected %d times", e, len(e.requests), e.times)
+		}
+	}
+}
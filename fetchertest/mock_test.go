@@ -0,0 +1,133 @@
+package fetchertest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/herb-go/fetcher"
+)
+
+type fakeTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeTB) finish() {
+	for _, f := range t.cleanups {
+		f()
+	}
+}
+
+func TestMockDoer(t *testing.T) {
+	m := NewMockDoer(t)
+	e := m.Expect(fetcher.Get, "/users/{id}").WithHeader("X-Tenant", "t1").RespondHeader("X-Total", "1").Respond(200, "user").Times(2)
+	m.Expect(fetcher.Post, "http://127.0.0.1/users").WithQuery("dry", "1").WithBody(`"new"`).RespondJSON(201, "created")
+	preset := fetcher.BuildPreset(fetcher.URL("http://127.0.0.1/users"), m)
+	var result string
+	for _, id := range []string{"1", "2"} {
+		resp, err := preset.With(fetcher.PathJoin(id), fetcher.SetHeader("X-Tenant", "t1")).FetchAndParse(fetcher.Should200(fetcher.AsString(&result)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != "user" || resp.Header.Get("X-Total") != "1" {
+			t.Fatal(result)
+		}
+	}
+	if len(e.Requests()) != 2 || e.Requests()[1].URL.Path != "/users/2" {
+		t.Fatal(e.Requests())
+	}
+	_, err := preset.With(fetcher.Post, fetcher.SetQuery("dry", "1")).FetchWithJSONBodyAndParse("new", fetcher.ShouldSuccess(fetcher.AsJSON(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "created" {
+		t.Fatal(result)
+	}
+}
+
+func TestMockDoerReport(t *testing.T) {
+	tb := &fakeTB{}
+	m := NewMockDoer(tb)
+	neterr := errors.New("network error")
+	m.Expect(fetcher.Delete, "/users/{id}").RespondError(neterr)
+	m.Expect(fetcher.Get, "/any").AnyTimes()
+	m.Expect(fetcher.Get, "/unused")
+	m.Expect(fetcher.Get, "/once")
+	preset := fetcher.BuildPreset(fetcher.URL("http://127.0.0.1/users"), m)
+	_, err := preset.With(fetcher.Delete, fetcher.PathJoin("1")).Fetch()
+	if err != neterr {
+		t.Fatal(err)
+	}
+	_, err = preset.With(fetcher.Put).FetchWithBody(bytes.NewBufferString("body"))
+	if !errors.Is(err, ErrUnexpectedRequest) || len(tb.errors) != 1 {
+		t.Fatal(err, tb.errors)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = fetcher.DoAndParse(m, fetcher.BuildPreset(fetcher.URL("http://127.0.0.1/once")), fetcher.AsBodyContent)
+	}
+	if !errors.Is(err, ErrUnexpectedRequest) || len(tb.errors) != 2 {
+		t.Fatal(err, tb.errors)
+	}
+	if len(m.Requests()) != 4 {
+		t.Fatal(m.Requests())
+	}
+	tb.finish()
+	if len(tb.errors) != 3 {
+		t.Fatal(tb.errors)
+	}
+}
+
+func TestMockDoerClient(t *testing.T) {
+	m := NewMockDoer(t)
+	m.Expect(fetcher.Get, "/status").Respond(204, "")
+	server := &fetcher.Server{
+		ServerInfo: fetcher.ServerInfo{URL: "http://127.0.0.1/status"},
+	}
+	server.Client.Use(m.Middleware)
+	resp, err := fetcher.MustPreset(server).FetchAndParse(fetcher.ShouldSuccess(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 204 {
+		t.Fatal(resp)
+	}
+}
+
+func TestMockDoerConcurrentRequests(t *testing.T) {
+	m := NewMockDoer(t)
+	e := m.Expect(fetcher.Get, "/users").AnyTimes()
+	preset := fetcher.BuildPreset(fetcher.URL("http://127.0.0.1/users"), m)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := preset.FetchAndParse(fetcher.Should200(nil))
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			e.Requests()
+		}()
+	}
+	wg.Wait()
+	if len(e.Requests()) != 10 {
+		t.Fatal(len(e.Requests()))
+	}
+}
@@ -0,0 +1,233 @@
+package fetcher
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//FormContentType content type of url-encoded form.
+var FormContentType = "application/x-www-form-urlencoded"
+
+//FormBody command which modify fetcher body to given values in url-encoded form format.
+//Content-Type header will be set to FormContentType.
+//Body can be replayed.
+func FormBody(values url.Values) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		f.Body = nil
+		f.BodySource = StringSource(values.Encode())
+		f.Header.Set("Content-Type", FormContentType)
+		return nil
+	})
+}
+
+//FormStructBody command which modify fetcher body to given struct in url-encoded form format.
+//Fields are encoded by EncodeForm.
+//Content-Type header will be set to FormContentType.
+func FormStructBody(v interface{}) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		values, err := EncodeForm(v)
+		if err != nil {
+			return err
+		}
+		return FormBody(values).Exec(f)
+	})
+}
+
+//EncodeForm encode given struct or struct pointer to url values by "form" tag.
+//Tag format is "name,omitempty".Field with tag "-" will be skipped,field without tag will use field name.
+//Supported field types are string,bool,numbers,time.Time,time.Duration,encoding.TextMarshaler,fmt.Stringer,pointers and slices of them.
+//encoding.TextMarshaler takes priority over basic kinds,fmt.Stringer is only used for types without basic kind.
+//Time is formatted in RFC3339 by default,or by "layout" tag which is "unix","unixmilli" or time layout.
+//Embedded structs will be flattened.
+//Return values and any error if raised.
+func EncodeForm(v interface{}) (url.Values, error) {
+	return encodeValues(v, "form")
+}
+
+//AsForm create parser which parse given values from response in url-encoded form format.
+func AsForm(values *url.Values) Parser {
+	return ParserFunc(func(resp *Response) error {
+		bs, err := resp.BodyContent()
+		if err != nil {
+			return err
+		}
+		v, err := url.ParseQuery(string(bs))
+		if err != nil {
+			return err
+		}
+		*values = v
+		return nil
+	})
+}
+
+func parseTag(tag string) (string, bool) {
+	parts := strings.Split(tag, ",")
+	omitempty := false
+	for _, v := range parts[1:] {
+		if strings.TrimSpace(v) == "omitempty" {
+			omitempty = true
+		}
+	}
+	return strings.TrimSpace(parts[0]), omitempty
+}
+
+func encodeValues(v interface{}, tagname string) (url.Values, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fetcher:%s encoding requires struct,got %s", tagname, rv.Kind())
+	}
+	if !rv.CanAddr() {
+		cp := reflect.New(rv.Type()).Elem()
+		cp.Set(rv)
+		rv = cp
+	}
+	err := encodeStructValues(values, rv, tagname)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func encodeStructValues(values url.Values, rv reflect.Value, tagname string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, hastag := field.Tag.Lookup(tagname)
+		if tag == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && !hastag {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				err := encodeStructValues(values, fv, tagname)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name, omitempty := parseTag(tag)
+		if name == "" {
+			name = field.Name
+		}
+		if omitempty && fv.IsZero() {
+			continue
+		}
+		strs, err := formatValues(fv, field.Tag.Get("layout"))
+		if err != nil {
+			return fmt.Errorf("fetcher:field %s:%w", field.Name, err)
+		}
+		for _, s := range strs {
+			values.Add(name, s)
+		}
+	}
+	return nil
+}
+
+func formatValues(rv reflect.Value, layout string) ([]string, error) {
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return []string{string(rv.Bytes())}, nil
+		}
+		result := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			strs, err := formatValues(rv.Index(i), layout)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, strs...)
+		}
+		return result, nil
+	}
+	s, ok, err := formatValue(rv, layout)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func formatValue(rv reflect.Value, layout string) (string, bool, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", false, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.CanInterface() {
+		switch v := rv.Interface().(type) {
+		case time.Time:
+			return formatTime(v, layout), true, nil
+		case time.Duration:
+			return v.String(), true, nil
+		}
+		if rv.CanAddr() {
+			if m, ok := rv.Addr().Interface().(encoding.TextMarshaler); ok {
+				bs, err := m.MarshalText()
+				if err != nil {
+					return "", false, err
+				}
+				return string(bs), true, nil
+			}
+		}
+		if m, ok := rv.Interface().(encoding.TextMarshaler); ok {
+			bs, err := m.MarshalText()
+			if err != nil {
+				return "", false, err
+			}
+			return string(bs), true, nil
+		}
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), true, nil
+	}
+	if rv.CanInterface() {
+		if s, ok := rv.Interface().(fmt.Stringer); ok {
+			return s.String(), true, nil
+		}
+	}
+	return "", false, fmt.Errorf("fetcher:unsupported type %s", rv.Type())
+}
+
+func formatTime(t time.Time, layout string) string {
+	switch layout {
+	case "":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.Format(layout)
+}
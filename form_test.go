@@ -0,0 +1,175 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testFormLevel int
+
+func (l *testFormLevel) MarshalText() ([]byte, error) {
+	return []byte("level" + string(rune('0'+int(*l)))), nil
+}
+
+type testFormStatus int
+
+func (s testFormStatus) String() string {
+	return "status" + string(rune('0'+int(s)))
+}
+
+type testFormName struct {
+	First string
+	Last  string
+}
+
+func (n testFormName) String() string {
+	return n.First + " " + n.Last
+}
+
+type testFormCode string
+
+func (c testFormCode) MarshalText() ([]byte, error) {
+	return []byte("code-" + string(c)), nil
+}
+
+type testFormBase struct {
+	ClientID string `form:"client_id"`
+}
+
+type testForm struct {
+	testFormBase
+	GrantType string        `form:"grant_type"`
+	Scope     []string      `form:"scope"`
+	Count     int           `form:"count,omitempty"`
+	Enabled   bool          `form:"enabled"`
+	Ratio     float64       `form:"ratio"`
+	Optional  *string       `form:"optional"`
+	Created   time.Time     `form:"created"`
+	Level     testFormLevel `form:"level"`
+	Timeout   time.Duration `form:"timeout"`
+	Ignored   string        `form:"-"`
+	Untagged  uint
+	private   string
+}
+
+func TestEncodeForm(t *testing.T) {
+	v := &testForm{
+		testFormBase: testFormBase{ClientID: "id"},
+		GrantType:    "client_credentials",
+		Scope:        []string{"read", "write"},
+		Enabled:      true,
+		Ratio:        0.5,
+		Created:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:        2,
+		Timeout:      time.Second,
+		Ignored:      "ignored",
+		Untagged:     7,
+		private:      "private",
+	}
+	values, err := EncodeForm(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Untagged=7&client_id=id&created=2020-01-02T03%3A04%3A05Z&enabled=true&grant_type=client_credentials&level=level2&ratio=0.5&scope=read&scope=write&timeout=1s"
+	if values.Encode() != expected {
+		t.Fatal(values.Encode())
+	}
+	optional := "opt"
+	v.Optional = &optional
+	v.Count = 3
+	values, err = EncodeForm(*v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.Get("optional") != "opt" || values.Get("count") != "3" || values.Get("level") != "level2" {
+		t.Fatal(values)
+	}
+	values, err = EncodeForm((*testForm)(nil))
+	if err != nil || len(values) != 0 {
+		t.Fatal(values, err)
+	}
+	_, err = EncodeForm("string")
+	if err == nil {
+		t.Fatal(err)
+	}
+	_, err = EncodeForm(struct{ M map[string]string }{M: map[string]string{}})
+	if err == nil {
+		t.Fatal(err)
+	}
+	values, err = EncodeForm(struct {
+		Status testFormStatus `form:"status"`
+		Name   testFormName   `form:"name"`
+		Code   testFormCode   `form:"code"`
+	}{Status: 3, Name: testFormName{First: "a", Last: "b"}, Code: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.Get("status") != "3" || values.Get("name") != "a b" || values.Get("code") != "code-x" {
+		t.Fatal(values)
+	}
+}
+
+func TestFormBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		values := url.Values{}
+		values.Set("access_token", r.PostForm.Get("client_id")+"-token")
+		values.Set("content_length", r.Header.Get("Content-Length"))
+		values["scope"] = r.PostForm["scope"]
+		w.Header().Set("Content-Type", FormContentType)
+		w.Write([]byte(values.Encode()))
+	}))
+	defer server.Close()
+	params := url.Values{}
+	params.Set("client_id", "id")
+	params.Add("scope", "read")
+	params.Add("scope", "write")
+	var result url.Values
+	_, err := FetchAndParse(NewPreset().With(URL(server.URL), Post, FormBody(params)), AsForm(&result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Get("access_token") != "id-token" || result.Get("content_length") != "35" || len(result["scope"]) != 2 {
+		t.Fatal(result)
+	}
+	result = nil
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, FormStructBody(&testForm{testFormBase: testFormBase{ClientID: "struct"}, Scope: []string{"read"}})), AsForm(&result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Get("access_token") != "struct-token" || len(result["scope"]) != 1 {
+		t.Fatal(result)
+	}
+	f := New()
+	err = FormStructBody("invalid").Exec(f)
+	if err == nil {
+		t.Fatal(err)
+	}
+	f = New()
+	err = FormBody(nil).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Body != nil || f.BodySource.Size() != 0 || f.Header.Get("Content-Type") != FormContentType {
+		t.Fatal(f)
+	}
+}
+
+func TestAsForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%zz"))
+	}))
+	defer server.Close()
+	var result url.Values
+	_, err := FetchAndParse(NewPreset().With(URL(server.URL)), AsForm(&result))
+	if err == nil {
+		t.Fatal(err)
+	}
+}
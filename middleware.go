@@ -0,0 +1,48 @@
+package fetcher
+
+import "net/http"
+
+//DoerFunc doer func type
+type DoerFunc func(*http.Request) (*http.Response, error)
+
+//Do do http request.
+//Return http response and any error if raised.
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//DoerMiddleware doer middleware type which wraps doer to a new doer.
+type DoerMiddleware func(Doer) Doer
+
+//ChainDoer chain base doer with given middlewares.
+//First middleware will be the outermost one.
+//Base doer will be returned if no middleware given.
+func ChainDoer(base Doer, mws ...DoerMiddleware) Doer {
+	d := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		d = mws[i](d)
+	}
+	return d
+}
+
+//RetryMiddleware create doer middleware which retries failed requests by given policy.
+//Default policy will be used if policy is nil.
+func RetryMiddleware(p *RetryPolicy) DoerMiddleware {
+	return func(d Doer) Doer {
+		return NewRetryDoer(d, p)
+	}
+}
+
+//WrapDoer command which wraps fetcher doer with given middlewares.
+//Default doer will be wrapped if fetcher doer is nil.
+//Should be executed after the command which sets doer,e.g. to limit requests of a preset.
+func WrapDoer(mws ...DoerMiddleware) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		d := f.Doer
+		if d == nil {
+			d = DefaultDoer()
+		}
+		f.Doer = ChainDoer(d, mws...)
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package fetcher
+
+import (
+	"net/http"
+	"testing"
+)
+
+func headerMiddleware(name string, value string) DoerMiddleware {
+	return func(d Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Add(name, value)
+			return d.Do(req)
+		})
+	}
+}
+
+func TestChainDoer(t *testing.T) {
+	var order []string
+	base := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		order = req.Header.Values("order")
+		return &http.Response{StatusCode: 200, Request: req, Body: http.NoBody}, nil
+	})
+	if ChainDoer(base) == nil {
+		t.Fatal()
+	}
+	d := ChainDoer(base, headerMiddleware("order", "1"), headerMiddleware("order", "2"))
+	req, err := http.NewRequest("GET", "http://127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "1" || order[1] != "2" {
+		t.Fatal(order)
+	}
+}
+
+func TestClientUse(t *testing.T) {
+	s := newEchoServer()
+	defer s.Close()
+	server := &Server{
+		ServerInfo: ServerInfo{URL: s.URL},
+	}
+	server.Client.Use(headerMiddleware("mw", "client"))
+	if len(server.Client.Middlewares()) != 1 {
+		t.Fatal(server.Client.Middlewares())
+	}
+	resp, err := MustPreset(server).FetchAndParse(Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("mw") != "client" {
+		t.Fatal(resp.Header)
+	}
+	cloned := server.Client.Clone()
+	cloned.Use(headerMiddleware("mw2", "cloned"))
+	if len(server.Client.Middlewares()) != 1 || len(cloned.Middlewares()) != 2 {
+		t.Fatal(cloned)
+	}
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2, err := cloned.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.Header.Get("mw") != "client" || resp2.Header.Get("mw2") != "cloned" {
+		t.Fatal(resp2.Header)
+	}
+}
@@ -1,7 +1,9 @@
 package fetcher
 
 import (
+	"io"
 	"net/http"
+	"sync"
 )
 
 //CloneHeader clone http header
@@ -10,6 +12,8 @@ func CloneHeader(h http.Header) http.Header {
 }
 
 //MergeHeader merge src header to dst
+//Only last value of every src header will be kept.
+//Use MergeHeaderWithMode to keep multiple values.
 func MergeHeader(dst http.Header, src http.Header) {
 	for name := range src {
 		for k := range src[name] {
@@ -18,9 +22,81 @@ func MergeHeader(dst http.Header, src http.Header) {
 	}
 }
 
+//HeaderMergeMode header merge mode type
+type HeaderMergeMode int
+
+const (
+	//HeaderMergeSet set dst header to last value of src header,same as MergeHeader.
+	HeaderMergeSet = HeaderMergeMode(iota)
+	//HeaderMergeReplace replace dst header with all values of src header.
+	HeaderMergeReplace
+	//HeaderMergeAppend append all values of src header to dst header.
+	HeaderMergeAppend
+)
+
+//MergeHeaderWithMode merge src header to dst with given mode.
+func MergeHeaderWithMode(dst http.Header, src http.Header, mode HeaderMergeMode) {
+	switch mode {
+	case HeaderMergeReplace:
+		for name := range src {
+			dst[http.CanonicalHeaderKey(name)] = append([]string{}, src[name]...)
+		}
+	case HeaderMergeAppend:
+		for name := range src {
+			for k := range src[name] {
+				dst.Add(name, src[name][k])
+			}
+		}
+	default:
+		MergeHeader(dst, src)
+	}
+}
+
 //CloneRequestBuilders clone request builders
 func CloneRequestBuilders(b []func(*http.Request) error) []func(*http.Request) error {
 	builders := make([]func(*http.Request) error, len(b))
 	copy(builders, b)
 	return builders
 }
+
+type lazyPipeReader struct {
+	locker  sync.Mutex
+	started bool
+	write   func(*io.PipeWriter)
+	discard func()
+	reader  *io.PipeReader
+	writer  *io.PipeWriter
+}
+
+//newLazyPipeReader create reader which starts given write func in new goroutine at first read.
+//Given discard func will be called if reader closed before read.
+func newLazyPipeReader(write func(*io.PipeWriter), discard func()) io.ReadCloser {
+	pr, pw := io.Pipe()
+	return &lazyPipeReader{
+		write:   write,
+		discard: discard,
+		reader:  pr,
+		writer:  pw,
+	}
+}
+
+func (r *lazyPipeReader) Read(p []byte) (int, error) {
+	r.locker.Lock()
+	if !r.started {
+		r.started = true
+		go r.write(r.writer)
+	}
+	r.locker.Unlock()
+	return r.reader.Read(p)
+}
+
+func (r *lazyPipeReader) Close() error {
+	r.locker.Lock()
+	started := r.started
+	r.started = true
+	r.locker.Unlock()
+	if !started && r.discard != nil {
+		r.discard()
+	}
+	return r.reader.Close()
+}
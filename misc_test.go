@@ -3,6 +3,7 @@ package fetcher
 import (
 	"bytes"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -50,3 +51,23 @@ func TestMisc(t *testing.T) {
 		t.Fatal(builders2)
 	}
 }
+
+func TestMergeHeaderWithMode(t *testing.T) {
+	src := http.Header{}
+	src.Add("X-Multi", "1")
+	src.Add("X-Multi", "2")
+	var cases = map[HeaderMergeMode]string{
+		HeaderMergeSet:     "2",
+		HeaderMergeReplace: "1,2",
+		HeaderMergeAppend:  "0,1,2",
+	}
+	for mode, expected := range cases {
+		dst := http.Header{}
+		dst.Set("X-Multi", "0")
+		dst.Set("X-Keep", "keep")
+		MergeHeaderWithMode(dst, src, mode)
+		if strings.Join(dst["X-Multi"], ",") != expected || dst.Get("X-Keep") != "keep" {
+			t.Fatal(mode, dst)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//ErrMultiPartReaderReused error raised when part created by reader is read more than once.
+var ErrMultiPartReaderReused = errors.New("fetcher:multipart reader part can not be reused")
+
+//DefaultMultiPartFileContentType default content type of file part if not detected by file extension.
+var DefaultMultiPartFileContentType = "application/octet-stream"
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+//MultiPartPart part of streaming multipart body.
+type MultiPartPart struct {
+	//FieldName form field name.
+	FieldName string
+	//FileName file name.
+	//Part will be a form field instead of file if empty.
+	FileName string
+	//ContentType part content type.
+	ContentType string
+	//Header extra part headers.
+	Header textproto.MIMEHeader
+	//Size part data size.
+	//Negative value means unknown size.
+	Size int64
+	//Open open part data.
+	Open    func() (io.ReadCloser, error)
+	path    string
+	oneshot bool
+}
+
+//SetContentType set part content type.
+//Return part self.
+func (p *MultiPartPart) SetContentType(contenttype string) *MultiPartPart {
+	p.ContentType = contenttype
+	return p
+}
+
+//SetHeader set extra part header by given key and value.
+//Return part self.
+func (p *MultiPartPart) SetHeader(key string, value string) *MultiPartPart {
+	if p.Header == nil {
+		p.Header = textproto.MIMEHeader{}
+	}
+	p.Header.Set(key, value)
+	return p
+}
+
+func (p *MultiPartPart) mimeHeader() textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	for k, v := range p.Header {
+		h[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.FieldName))
+	if p.FileName != "" {
+		disposition = disposition + fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(p.FileName))
+	}
+	h.Set("Content-Disposition", disposition)
+	if p.ContentType != "" {
+		h.Set("Content-Type", p.ContentType)
+	}
+	return h
+}
+
+//MultiPartStream streaming multipart command.
+//Parts will be opened and written lazily through pipe when request body is read,
+//so that large files will not be buffered in memory.
+//Body will be closed automatically,no need to close it manually.
+type MultiPartStream struct {
+	boundary string
+	parts    []*MultiPartPart
+}
+
+//NewMultiPartStream create new streaming multipart command.
+func NewMultiPartStream() *MultiPartStream {
+	return &MultiPartStream{
+		boundary: multipart.NewWriter(ioutil.Discard).Boundary(),
+	}
+}
+
+//Boundary return multipart boundary.
+func (s *MultiPartStream) Boundary() string {
+	return s.boundary
+}
+
+//SetBoundary set multipart boundary.
+//Return any error if raised.
+func (s *MultiPartStream) SetBoundary(boundary string) error {
+	err := multipart.NewWriter(ioutil.Discard).SetBoundary(boundary)
+	if err != nil {
+		return err
+	}
+	s.boundary = boundary
+	return nil
+}
+
+//FormDataContentType return content type of multipart body.
+func (s *MultiPartStream) FormDataContentType() string {
+	b := s.boundary
+	if strings.ContainsAny(b, `()<>@,;:\"/[]?= `) {
+		b = `"` + b + `"`
+	}
+	return "multipart/form-data; boundary=" + b
+}
+
+//AddPart add given part to stream.
+//Return part added.
+func (s *MultiPartStream) AddPart(p *MultiPartPart) *MultiPartPart {
+	s.parts = append(s.parts, p)
+	return p
+}
+
+//AddField add form field with given name and value.
+//Return part added.
+func (s *MultiPartStream) AddField(name string, value string) *MultiPartPart {
+	return s.AddPart(&MultiPartPart{
+		FieldName: name,
+		Size:      int64(len(value)),
+		Open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(value)), nil
+		},
+	})
+}
+
+//AddFile add file part with given field name and file path.
+//File name will be base name of path,content type will be detected by file extension.
+//File size will be read when command executed,file will be opened when request body is read.
+//Return part added.
+func (s *MultiPartStream) AddFile(fieldname string, path string) *MultiPartPart {
+	contenttype := mime.TypeByExtension(filepath.Ext(path))
+	if contenttype == "" {
+		contenttype = DefaultMultiPartFileContentType
+	}
+	return s.AddPart(&MultiPartPart{
+		FieldName:   fieldname,
+		FileName:    filepath.Base(path),
+		ContentType: contenttype,
+		Size:        -1,
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		path: path,
+	})
+}
+
+//AddReader add file part with given field name,file name,reader and size.
+//Size should be negative if unknown.
+//Reader can only be read once,so request with reader part can not be replayed by retries or redirects.
+//Return part added.
+func (s *MultiPartStream) AddReader(fieldname string, filename string, r io.Reader, size int64) *MultiPartPart {
+	var locker sync.Mutex
+	used := false
+	return s.AddPart(&MultiPartPart{
+		FieldName:   fieldname,
+		FileName:    filename,
+		ContentType: DefaultMultiPartFileContentType,
+		Size:        size,
+		Open: func() (io.ReadCloser, error) {
+			locker.Lock()
+			defer locker.Unlock()
+			if used {
+				return nil, ErrMultiPartReaderReused
+			}
+			used = true
+			if rc, ok := r.(io.ReadCloser); ok {
+				return rc, nil
+			}
+			return ioutil.NopCloser(r), nil
+		},
+		oneshot: true,
+	})
+}
+
+type countWriter int64
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	*w += countWriter(len(p))
+	return len(p), nil
+}
+
+func (s *MultiPartStream) contentLength(parts []*MultiPartPart) (int64, error) {
+	var counter countWriter
+	w := multipart.NewWriter(&counter)
+	err := w.SetBoundary(s.boundary)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, p := range parts {
+		if p.Size < 0 {
+			return -1, nil
+		}
+		_, err = w.CreatePart(p.mimeHeader())
+		if err != nil {
+			return 0, err
+		}
+		size = size + p.Size
+	}
+	err = w.Close()
+	if err != nil {
+		return 0, err
+	}
+	return int64(counter) + size, nil
+}
+
+func (s *MultiPartStream) write(pw *io.PipeWriter, parts []*MultiPartPart) {
+	w := multipart.NewWriter(pw)
+	err := w.SetBoundary(s.boundary)
+	if err != nil {
+		pw.CloseWithError(err)
+		return
+	}
+	for _, p := range parts {
+		err = writeMultiPartPart(w, p)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+	pw.CloseWithError(w.Close())
+}
+
+func writeMultiPartPart(w *multipart.Writer, p *MultiPartPart) error {
+	pw, err := w.CreatePart(p.mimeHeader())
+	if err != nil {
+		return err
+	}
+	if p.Open == nil {
+		return nil
+	}
+	r, err := p.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	n, err := io.Copy(pw, r)
+	if err != nil {
+		return err
+	}
+	if p.Size >= 0 && n != p.Size {
+		return fmt.Errorf("fetcher:multipart part %q size mismatch,%d expected,%d written", p.FieldName, p.Size, n)
+	}
+	return nil
+}
+
+func (s *MultiPartStream) newBody(parts []*MultiPartPart) io.ReadCloser {
+	return newLazyPipeReader(func(pw *io.PipeWriter) {
+		s.write(pw, parts)
+	}, nil)
+}
+
+//Exec exec command to modify fetcher.
+//Content-Type header will be set,Content-Length will be set if sizes of all parts are known.
+//Return any error if raised.
+func (s *MultiPartStream) Exec(f *Fetcher) error {
+	parts := make([]*MultiPartPart, len(s.parts))
+	replayable := true
+	for k, v := range s.parts {
+		p := *v
+		if p.path != "" {
+			info, err := os.Stat(p.path)
+			if err != nil {
+				return err
+			}
+			p.Size = info.Size()
+		}
+		if p.oneshot {
+			replayable = false
+		}
+		parts[k] = &p
+	}
+	length, err := s.contentLength(parts)
+	if err != nil {
+		return err
+	}
+	body := s.newBody(parts)
+	f.Body = body
+	f.BodySource = nil
+	f.Header.Set("Content-Type", s.FormDataContentType())
+	f.AppendBuilder(func(req *http.Request) error {
+		if req.Body != body {
+			return nil
+		}
+		if length >= 0 {
+			req.ContentLength = length
+		}
+		if replayable {
+			req.GetBody = func() (io.ReadCloser, error) {
+				return s.newBody(parts), nil
+			}
+		}
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,191 @@
+package fetcher
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newMultiPartServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		result := []string{strconv.FormatInt(r.ContentLength, 10)}
+		for {
+			p, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			data, err := ioutil.ReadAll(p)
+			if err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			result = append(result, p.FormName()+":"+p.FileName()+":"+p.Header.Get("Content-Type")+":"+p.Header.Get("X-Extra")+":"+string(data))
+		}
+		w.Write([]byte(strings.Join(result, "|")))
+	}))
+}
+
+func TestMultiPartStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchermultipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data.txt")
+	err = ioutil.WriteFile(path, []byte("file content"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newMultiPartServer()
+	defer server.Close()
+	s := NewMultiPartStream()
+	s.AddField("name", "value")
+	s.AddFile("file", path).SetHeader("X-Extra", "extra")
+	s.AddPart(&MultiPartPart{FieldName: "empty", Size: 0})
+	var result string
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, s), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(result, "|")
+	if len(parts) != 4 || parts[0] == "-1" || parts[1] != "name::::value" {
+		t.Fatal(result)
+	}
+	if !strings.HasPrefix(parts[2], "file:data.txt:text/plain") || !strings.HasSuffix(parts[2], ":extra:file content") {
+		t.Fatal(parts[2])
+	}
+	f := New()
+	err = s.Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strconv.Itoa(len(data)) != parts[0] || req.ContentLength != int64(len(data)) {
+		t.Fatal(len(data), parts[0], req.ContentLength)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(data), s.Boundary()).ReadForm(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Value["name"][0] != "value" || len(form.File["file"]) != 1 {
+		t.Fatal(form)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := ioutil.ReadAll(body)
+	if err != nil || !bytes.Equal(replayed, data) {
+		t.Fatal(string(replayed), err)
+	}
+	os.Remove(path)
+	err = s.Exec(New())
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiPartStreamReader(t *testing.T) {
+	server := newMultiPartServer()
+	defer server.Close()
+	s := NewMultiPartStream()
+	err := s.SetBoundary("custom boundary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.FormDataContentType() != `multipart/form-data; boundary="custom boundary"` {
+		t.Fatal(s.FormDataContentType())
+	}
+	s.AddReader("upload", "upload.bin", strings.NewReader("streamed"), -1).SetContentType("application/x-test")
+	var result string
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, s), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "-1|upload:upload.bin:application/x-test::streamed" {
+		t.Fatal(result)
+	}
+	f := New()
+	err = s.Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody != nil {
+		t.Fatal(req)
+	}
+	_, err = ioutil.ReadAll(req.Body)
+	if !errors.Is(err, ErrMultiPartReaderReused) {
+		t.Fatal(err)
+	}
+	s = NewMultiPartStream()
+	s.AddReader("upload", "upload.bin", strings.NewReader("short"), 10)
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, s), AsString(&result))
+	if err == nil {
+		t.Fatal(err)
+	}
+	if s.SetBoundary("") == nil {
+		t.Fatal()
+	}
+}
+
+func TestMultiPartStreamClose(t *testing.T) {
+	s := NewMultiPartStream()
+	s.AddField("name", "value")
+	f := New()
+	err := s.Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err := f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = req.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(req.Body)
+	if err == nil {
+		t.Fatal(err)
+	}
+	f = New()
+	err = s.Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Body(strings.NewReader("replaced")).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _, err = f.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != int64(len("replaced")) {
+		t.Fatal(req.ContentLength)
+	}
+}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 )
@@ -159,6 +160,13 @@ func Fetch(cmds ...Command) (*Response, error) {
 	return FetchAndParse(Concat(cmds...), DefaultParser)
 }
 
+//FetchContext create new fetcher with given context,exec commands and fetch response.
+//Return http response and any error if raised.
+//Response returned will be parsed by defualt parser.
+func FetchContext(ctx context.Context, cmds ...Command) (*Response, error) {
+	return FetchAndParseContext(ctx, Concat(cmds...), DefaultParser)
+}
+
 //FetchAndParse fetch request and prase response with given preset and parser if no error raised.
 //Return response fetched and any error raised when fetching or parsing.
 //Response returned will be parsed by given parser or defualt parser if nill given.
@@ -174,6 +182,13 @@ func FetchAndParse(preset *Preset, parser Parser) (*Response, error) {
 	return resp, nil
 }
 
+//FetchAndParseContext fetch request with given context and prase response with given preset and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func FetchAndParseContext(ctx context.Context, preset *Preset, parser Parser) (*Response, error) {
+	return FetchAndParse(preset.Concat(SetContext(ctx)), parser)
+}
+
 //DoAndParse do request and prase response with given doer,preset and parser if no error raised.
 //Return response fetched and any error raised when fetching or parsing.
 //Response returned will be parsed by given parser or defualt parser if nill given.
@@ -181,6 +196,13 @@ func DoAndParse(doer Doer, preset *Preset, parser Parser) (*Response, error) {
 	return FetchAndParse(preset.Concat(SetDoer(doer)), parser)
 }
 
+//DoAndParseContext do request with given context and prase response with given doer,preset and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func DoAndParseContext(ctx context.Context, doer Doer, preset *Preset, parser Parser) (*Response, error) {
+	return FetchAndParse(preset.Concat(SetDoer(doer), SetContext(ctx)), parser)
+}
+
 //FetchWithBodyAndParse fetch request and prase response with given preset ,body and parser if no error raised.
 //Return response fetched and any error raised when fetching or parsing.
 //Response returned will be parsed by given parser or defualt parser if nill given.
@@ -188,6 +210,13 @@ func FetchWithBodyAndParse(preset *Preset, body io.Reader, parser Parser) (*Resp
 	return FetchAndParse(preset.Concat(Body(body)), parser)
 }
 
+//FetchWithBodyAndParseContext fetch request with given context and prase response with given preset ,body and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func FetchWithBodyAndParseContext(ctx context.Context, preset *Preset, body io.Reader, parser Parser) (*Response, error) {
+	return FetchAndParse(preset.Concat(Body(body), SetContext(ctx)), parser)
+}
+
 //FetchWithJSONBodyAndParse fetch request and prase response with given preset , body as json and parser if no error raised.
 //Return response fetched and any error raised when fetching or parsing.
 //Response returned will be parsed by given parser or defualt parser if nill given.
@@ -195,6 +224,13 @@ func FetchWithJSONBodyAndParse(preset *Preset, body interface{}, parser Parser)
 	return FetchAndParse(preset.Concat(JSONBody(body)), parser)
 }
 
+//FetchWithJSONBodyAndParseContext fetch request with given context and prase response with given preset , body as json and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func FetchWithJSONBodyAndParseContext(ctx context.Context, preset *Preset, body interface{}, parser Parser) (*Response, error) {
+	return FetchAndParse(preset.Concat(JSONBody(body), SetContext(ctx)), parser)
+}
+
 //DoWithBodyAndParse do request and prase response with given doer,preset,body and parser if no error raised.
 //Return response fetched and any error raised when fetching or parsing.
 //Response returned will be parsed by given parser or defualt parser if nill given.
@@ -202,9 +238,23 @@ func DoWithBodyAndParse(doer Doer, preset *Preset, body io.Reader, parser Parser
 	return FetchAndParse(preset.Concat(SetDoer(doer), Body(body)), parser)
 }
 
+//DoWithBodyAndParseContext do request with given context and prase response with given doer,preset,body and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func DoWithBodyAndParseContext(ctx context.Context, doer Doer, preset *Preset, body io.Reader, parser Parser) (*Response, error) {
+	return FetchAndParse(preset.Concat(SetDoer(doer), Body(body), SetContext(ctx)), parser)
+}
+
 //DoWithJSONBodyAndParse do request and prase response with given doer,preset,body as json and parser if no error raised.
 //Return response fetched and any error raised when fetching or parsing.
 //Response returned will be parsed by given parser or defualt parser if nill given.
 func DoWithJSONBodyAndParse(doer Doer, preset *Preset, body interface{}, parser Parser) (*Response, error) {
 	return FetchAndParse(preset.Concat(SetDoer(doer), JSONBody(body)), parser)
 }
+
+//DoWithJSONBodyAndParseContext do request with given context and prase response with given doer,preset,body as json and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func DoWithJSONBodyAndParseContext(ctx context.Context, doer Doer, preset *Preset, body interface{}, parser Parser) (*Response, error) {
+	return FetchAndParse(preset.Concat(SetDoer(doer), JSONBody(body), SetContext(ctx)), parser)
+}
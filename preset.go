@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -125,6 +126,42 @@ func (p *Preset) FetchWithJSONBodyAndParse(body interface{}, parser Parser) (*Re
 	return FetchAndParse(p.Concat(JSONBody(body)), parser)
 }
 
+//FetchContext fetch request with given context.
+//Preset and commands will exec on new fetcher by which fetching response.
+//Return http response and any error if raised.
+//Response returned will be parsed by defualt parser.
+func (p *Preset) FetchContext(ctx context.Context, cmds ...Command) (*Response, error) {
+	return FetchContext(ctx, p.Concat(cmds...))
+}
+
+//FetchWithBodyContext fetch request with given context and body.
+//Return http response and any error if raised.
+//Response returned will be parsed by defualt parser.
+func (p *Preset) FetchWithBodyContext(ctx context.Context, body io.Reader) (*Response, error) {
+	return p.FetchContext(ctx, Body(body))
+}
+
+//FetchAndParseContext fetch request with given context and prase response with given parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func (p *Preset) FetchAndParseContext(ctx context.Context, parser Parser) (*Response, error) {
+	return FetchAndParseContext(ctx, p, parser)
+}
+
+//FetchWithBodyAndParseContext fetch request with given context and prase response with given body and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func (p *Preset) FetchWithBodyAndParseContext(ctx context.Context, body io.Reader, parser Parser) (*Response, error) {
+	return FetchWithBodyAndParseContext(ctx, p, body, parser)
+}
+
+//FetchWithJSONBodyAndParseContext fetch request with given context and prase response with given body as json and parser if no error raised.
+//Return response fetched and any error raised when fetching or parsing.
+//Request will be canceled when context done.
+func (p *Preset) FetchWithJSONBodyAndParseContext(ctx context.Context, body interface{}, parser Parser) (*Response, error) {
+	return FetchWithJSONBodyAndParseContext(ctx, p, body, parser)
+}
+
 //NewPreset create new preset
 func NewPreset() *Preset {
 	return nil
@@ -143,6 +180,7 @@ func BuildPreset(cmds ...Command) *Preset {
 //ServerInfo server info struct
 type ServerInfo struct {
 	//URL server host url
+	//RFC 6570 uri template is allowed,which will be expanded with template vars when fetching.
 	URL string
 	//Header http header
 	Header http.Header
@@ -165,7 +203,13 @@ func (s *ServerInfo) MergeURL(url string) *ServerInfo {
 }
 
 //MustJoin clone and join with given urlpath
+//Urlpath will be joined before query and fragment expressions if url is uri template.
 func (s *ServerInfo) MustJoin(urlpath string) *ServerInfo {
+	if IsURITemplate(s.URL) {
+		si := s.Clone()
+		si.URL = joinURITemplate(s.URL, urlpath)
+		return si
+	}
 	su, err := url.Parse(s.URL)
 	if err != nil {
 		panic(err)
@@ -177,9 +221,14 @@ func (s *ServerInfo) MustJoin(urlpath string) *ServerInfo {
 }
 
 //CreatePreset create new preset.
+//Url will be used as TemplateURL if it is uri template.
 //Return preset created and any error raised.
 func (s *ServerInfo) CreatePreset() (*Preset, error) {
-	p := BuildPreset(URL(s.URL), Method(s.Method), Header(s.Header))
+	u := URL(s.URL)
+	if IsURITemplate(s.URL) {
+		u = TemplateURL(s.URL)
+	}
+	p := BuildPreset(u, Method(s.Method), Header(s.Header))
 	return p, nil
 }
 
@@ -206,11 +255,10 @@ type Server struct {
 }
 
 //CreatePreset create new preset.
+//Server client will be used as doer,so that Reset and Reload of client take effect on created presets.
 //Return preset created and any error raised.
 func (s *Server) CreatePreset() (*Preset, error) {
-	var err error
-
-	doer, err := s.Client.CreateDoer()
+	err := s.Client.SelfCheck()
 	if err != nil {
 		return nil, err
 	}
@@ -218,38 +266,49 @@ func (s *Server) CreatePreset() (*Preset, error) {
 	if err != nil {
 		return nil, err
 	}
-	return p.Concat(SetDoer(doer)), nil
+	return p.Concat(SetDoer(&s.Client)), nil
+}
+
+//Close release transports held by server client.
+//Return any error if raised.
+func (s *Server) Close() error {
+	return s.Client.Close()
 }
 
 //Clone clone a new server config
+//Cloned server client shares pooled transport references with server client,
+//so cloned server needs not to be closed,and references will be released when server closed.
 func (s *Server) Clone() *Server {
 	return &Server{
 		ServerInfo: *s.ServerInfo.Clone(),
-		Client:     *s.Client.Clone(),
+		Client:     *s.Client.shareTransports(),
 	}
 }
 
 //MergeURL clone and merge with given url
+//Cloned server shares pooled transport references as Clone.
 func (s *Server) MergeURL(url string) *Server {
 	return &Server{
 		ServerInfo: *s.ServerInfo.MergeURL(url),
-		Client:     *s.Client.Clone(),
+		Client:     *s.Client.shareTransports(),
 	}
 }
 
-//MergeURL clone and merge with given method
+//MergeMethod clone and merge with given method
+//Cloned server shares pooled transport references as Clone.
 func (s *Server) MergeMethod(method string) *Server {
 	return &Server{
 		ServerInfo: *s.ServerInfo.MergeMethod(method),
-		Client:     *s.Client.Clone(),
+		Client:     *s.Client.shareTransports(),
 	}
 }
 
 //MustJoin clone and join with given urlpath
+//Cloned server shares pooled transport references as Clone.
 func (s *Server) MustJoin(urlpath string) *Server {
 	return &Server{
 		ServerInfo: *s.ServerInfo.MustJoin(urlpath),
-		Client:     *s.Client.Clone(),
+		Client:     *s.Client.shareTransports(),
 	}
 }
 
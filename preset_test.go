@@ -2,7 +2,12 @@ package fetcher
 
 import (
 	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPreset(t *testing.T) {
@@ -228,3 +233,72 @@ func TestCloneServer(t *testing.T) {
 		t.Fatal(cloned)
 	}
 }
+
+func TestPresetContext(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer s.Close()
+	var sc = &Server{
+		ServerInfo: ServerInfo{
+			URL: s.URL,
+		},
+	}
+	preset := MustPreset(sc)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := preset.FetchContext(ctx)
+	if err == nil || ctx.Err() == nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = preset.FetchAndParseContext(ctx, Should200(nil))
+	if err == nil {
+		t.Fatal(err)
+	}
+	_, err = DoAndParseContext(ctx, nil, preset, Should200(nil))
+	if err == nil {
+		t.Fatal(err)
+	}
+	echo := newEchoServer()
+	defer echo.Close()
+	preset = MustPreset(&ServerInfo{URL: echo.URL})
+	var result string
+	_, err = preset.FetchWithJSONBodyAndParseContext(context.Background(), "12345", Should200(AsJSON(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "12345" {
+		t.Fatal(result)
+	}
+}
+
+func TestServerInfoMultiValueHeader(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(r.Header.Values("Accept"), ",")))
+	}))
+	defer s.Close()
+	si := &ServerInfo{
+		URL:    s.URL,
+		Header: http.Header{"Accept": {"a/b", "c/d"}},
+	}
+	var result string
+	_, err := FetchAndParse(MustPreset(si), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "a/b,c/d" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(MustPreset(si).With(Header(http.Header{"Accept": {"e/f", "g/h"}})), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "e/f,g/h" {
+		t.Fatal(result)
+	}
+}
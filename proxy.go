@@ -0,0 +1,157 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//ParseProxy parse proxy url.
+//Supported schemes are "http","https","socks5" and "socks5h".
+//Return nil if index is empty string.
+//Return proxy url and any error if raised.
+func ParseProxy(index string) (*url.URL, error) {
+	if index == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(index)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher:invalid proxy %q:%w", index, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("fetcher:invalid proxy scheme %q", index)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("fetcher:invalid proxy host %q", index)
+	}
+	return u, nil
+}
+
+//NoProxy proxy bypass list.
+//Every item should be one of:
+//"*" which matches all hosts,
+//CIDR like "10.0.0.0/8" or ip which matches ip hosts,
+//domain like "example.com" or ".example.com" which matches domain and its subdomains.
+type NoProxy []string
+
+//Match check if given host should bypass proxy.
+//Port in host will be ignored.
+func (n NoProxy) Match(host string) bool {
+	return matchHostPatterns(n, host)
+}
+
+//Validate check if every item in list is valid.
+//Return any error if raised.
+func (n NoProxy) Validate() error {
+	return validateHostPatterns(n)
+}
+
+func matchHostPatterns(patterns []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	ip := net.ParseIP(host)
+	for _, v := range patterns {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if v == "*" {
+			return true
+		}
+		if ip != nil {
+			if _, cidr, err := net.ParseCIDR(v); err == nil {
+				if cidr.Contains(ip) {
+					return true
+				}
+				continue
+			}
+			if vip := net.ParseIP(v); vip != nil && vip.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		v = strings.TrimPrefix(v, ".")
+		if host == v || strings.HasSuffix(host, "."+v) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateHostPatterns(patterns []string) error {
+	for _, v := range patterns {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			if _, _, err := net.ParseCIDR(v); err != nil {
+				return fmt.Errorf("fetcher:invalid cidr %q", v)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) proxyURL() (*url.URL, error) {
+	u, err := ParseProxy(c.Proxy)
+	if err != nil || u == nil {
+		return u, err
+	}
+	if c.ProxyUsername != "" || c.ProxyPassword != "" {
+		u.User = url.UserPassword(c.ProxyUsername, c.ProxyPassword)
+	}
+	return u, nil
+}
+
+func (c *Client) applyProxy(transport *http.Transport, dial DialContextFunc) error {
+	noproxy := NoProxy(c.NoProxy)
+	err := noproxy.Validate()
+	if err != nil {
+		return err
+	}
+	u, err := c.proxyURL()
+	if err != nil {
+		return err
+	}
+	transport.DialContext = dial
+	if u == nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if noproxy.Match(req.URL.Host) {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		}
+		return nil
+	}
+	if u.Scheme == "socks5" || u.Scheme == "socks5h" {
+		d := &SOCKS5Dialer{
+			ProxyAddr:     u.Host,
+			RemoteResolve: u.Scheme == "socks5h",
+			Forward:       dial,
+		}
+		if u.User != nil {
+			d.Username = u.User.Username()
+			d.Password, _ = u.User.Password()
+		}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			if noproxy.Match(addr) {
+				return dial(ctx, network, addr)
+			}
+			return d.DialContext(ctx, network, addr)
+		}
+		return nil
+	}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if noproxy.Match(req.URL.Host) {
+			return nil, nil
+		}
+		return u, nil
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ParseRate parse rate string to requests per second.
+//Rate string format is "count/unit",unit should be one of "s","m","h".
+//Unit will be "s" if omitted,e.g. "10/s","600/m","5".
+func ParseRate(rate string) (float64, error) {
+	rate = strings.TrimSpace(rate)
+	count := rate
+	unit := "s"
+	if i := strings.Index(rate, "/"); i >= 0 {
+		count = strings.TrimSpace(rate[:i])
+		unit = strings.TrimSpace(rate[i+1:])
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("fetcher:invalid rate %q", rate)
+	}
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	}
+	return 0, fmt.Errorf("fetcher:invalid rate unit %q", rate)
+}
+
+//TokenBucket token bucket struct.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	locker sync.Mutex
+}
+
+//NewTokenBucket create new token bucket with given rate in requests per second and burst size.
+//Bucket will be full when created.
+//Burst will be 1 if burst less than 1.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+//Allow take a token if available.
+//Return whether token is taken.
+func (b *TokenBucket) Allow() bool {
+	if b.rate <= 0 {
+		return true
+	}
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+//Wait block until a token is available or context is done.
+//Return context error if context done before token available.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+	b.locker.Lock()
+	b.refill(time.Now())
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.locker.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.locker.Lock()
+		b.tokens++
+		b.locker.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+//RateLimitKeyByHost rate limit key func which use request url host as key.
+func RateLimitKeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+//RateLimiter rate limiter which tracks token buckets by key.
+//Rate limiter can be shared by multiple doers.
+type RateLimiter struct {
+	//Rate default requests per second.
+	//Requests will not be limited if Rate less than or equal to 0.
+	Rate float64
+	//Burst default burst size.
+	Burst int
+	//KeyFunc func which return bucket key of request.
+	//All requests will share one bucket if nil.
+	KeyFunc func(*http.Request) string
+	locker  sync.Mutex
+	limits  map[string]rateLimit
+	buckets map[string]*TokenBucket
+}
+
+//NewRateLimiter create new rate limiter with given default rate and burst.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		Rate:    rate,
+		Burst:   burst,
+		limits:  map[string]rateLimit{},
+		buckets: map[string]*TokenBucket{},
+	}
+}
+
+//SetLimit set rate and burst of given key.
+//Existing bucket of key will be replaced.
+func (l *RateLimiter) SetLimit(key string, rate float64, burst int) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if l.limits == nil {
+		l.limits = map[string]rateLimit{}
+	}
+	l.limits[key] = rateLimit{rate: rate, burst: burst}
+	delete(l.buckets, key)
+}
+
+//Bucket return token bucket of given key.
+func (l *RateLimiter) Bucket(key string) *TokenBucket {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if l.buckets == nil {
+		l.buckets = map[string]*TokenBucket{}
+	}
+	b := l.buckets[key]
+	if b == nil {
+		limit, ok := l.limits[key]
+		if !ok {
+			limit = rateLimit{rate: l.Rate, burst: l.Burst}
+		}
+		b = NewTokenBucket(limit.rate, limit.burst)
+		l.buckets[key] = b
+	}
+	return b
+}
+
+//Wait block until request is allowed or request context is done.
+//Return context error if context done before request allowed.
+func (l *RateLimiter) Wait(req *http.Request) error {
+	var key string
+	if l.KeyFunc != nil {
+		key = l.KeyFunc(req)
+	}
+	return l.Bucket(key).Wait(req.Context())
+}
+
+//Wrap wrap given doer with rate limiter.
+//Wrap can be used as DoerMiddleware.
+func (l *RateLimiter) Wrap(d Doer) Doer {
+	return &RateLimitDoer{
+		Doer:    d,
+		Limiter: l,
+	}
+}
+
+//RateLimitDoer doer which limits request rate by rate limiter.
+type RateLimitDoer struct {
+	//Doer doer by which requests will be done.
+	Doer Doer
+	//Limiter rate limiter
+	Limiter *RateLimiter
+}
+
+//Do do http request when rate limiter allowed.
+//Return http response and any error if raised.
+func (d *RateLimitDoer) Do(req *http.Request) (*http.Response, error) {
+	err := d.Limiter.Wait(req)
+	if err != nil {
+		return nil, err
+	}
+	return d.Doer.Do(req)
+}
+
+//CreateRateLimiter create rate limiter by given rate string,burst and whether limited per host.
+//Burst will be rate rounded up if burst less than 1.
+//Return rate limiter created and any error if raised.
+func CreateRateLimiter(rate string, burst int, perHost bool) (*RateLimiter, error) {
+	r, err := ParseRate(rate)
+	if err != nil {
+		return nil, err
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(r))
+	}
+	l := NewRateLimiter(r, burst)
+	if perHost {
+		l.KeyFunc = RateLimitKeyByHost
+	}
+	return l, nil
+}
@@ -0,0 +1,143 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	var cases = map[string]float64{
+		"10/s":   10,
+		"600/m":  10,
+		"36/h":   0.01,
+		"5":      5,
+		" 2 / s": 2,
+	}
+	for k, v := range cases {
+		r, err := ParseRate(k)
+		if err != nil || r != v {
+			t.Fatal(k, r, err)
+		}
+	}
+	for _, v := range []string{"", "abc/s", "10/d", "-1/s", "0"} {
+		_, err := ParseRate(v)
+		if err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(100, 2)
+	if !b.Allow() || !b.Allow() || b.Allow() {
+		t.Fatal(b)
+	}
+	start := time.Now()
+	err := b.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Fatal(time.Since(start))
+	}
+	b = NewTokenBucket(0.001, 1)
+	if !b.Allow() {
+		t.Fatal(b)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = b.Wait(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	if !NewTokenBucket(0, 0).Allow() {
+		t.Fatal()
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(0.001, 1)
+	l.KeyFunc = RateLimitKeyByHost
+	l.SetLimit("unlimited", 0, 0)
+	d := l.Wrap(DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Request: req, Body: http.NoBody}, nil
+	}))
+	for _, host := range []string{"a", "b", "unlimited", "unlimited"} {
+		_, err := DoAndParse(d, BuildPreset(URL("http://"+host)), AsUselessBody)
+		if err != nil {
+			t.Fatal(host, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := DoAndParseContext(ctx, d, BuildPreset(URL("http://a")), nil)
+	if err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	s := newEchoServer()
+	defer s.Close()
+	server := &Server{
+		ServerInfo: ServerInfo{URL: s.URL},
+		Client: Client{
+			RateLimit: "0.01/s",
+		},
+	}
+	preset := MustPreset(server)
+	_, err := preset.FetchAndParse(Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = preset.FetchAndParseContext(ctx, Should200(nil))
+	if err == nil {
+		t.Fatal(err)
+	}
+	other, err := server.CreatePreset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.FetchAndParseContext(ctx, Should200(nil))
+	if err == nil {
+		t.Fatal(err)
+	}
+	doer, err := server.Client.CreateDoer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DoAndParseContext(ctx, doer, MustPreset(&server.ServerInfo), Should200(nil))
+	if err == nil {
+		t.Fatal(err)
+	}
+	err = server.Client.Reload(&Client{RateLimit: "100/s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doer, err = server.Client.CreateDoer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DoAndParse(doer, MustPreset(&server.ServerInfo), Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewRateLimiter(0.001, 1)
+	limited := MustPreset(&ServerInfo{URL: s.URL}).With(WrapDoer(limiter.Wrap))
+	_, err = limited.FetchAndParse(Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = limited.FetchAndParseContext(ctx, Should200(nil))
+	if err == nil {
+		t.Fatal(err)
+	}
+	c := &Client{RateLimit: "invalid"}
+	if c.SelfCheck() == nil {
+		t.Fatal(c)
+	}
+}
@@ -0,0 +1,131 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//DefaultMaxRedirects default max redirects followed by client.
+var DefaultMaxRedirects = 10
+
+//DefaultRedirectSafeHeaders default request headers which will be kept when redirecting to another origin.
+//All other headers will be stripped.
+var DefaultRedirectSafeHeaders = []string{
+	"Accept",
+	"Accept-Charset",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Cache-Control",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"Pragma",
+	"User-Agent",
+}
+
+//ErrTooManyRedirects error raised when redirects exceed max redirects.
+var ErrTooManyRedirects = errors.New("fetcher:too many redirects")
+
+//ErrRedirectNotAllowed error raised when redirect target host is not allowed.
+var ErrRedirectNotAllowed = errors.New("fetcher:redirect not allowed")
+
+//RedirectPolicy redirect policy used by http client.
+type RedirectPolicy struct {
+	//MaxRedirects max redirects to follow.
+	//DefaultMaxRedirects will be used if less than or equal to 0.
+	MaxRedirects int
+	//Disable whether redirects should not be followed.
+	//Redirect response will be returned as final response if true.
+	Disable bool
+	//AllowedHosts hosts which redirects are allowed to.
+	//Item could be "*",domain,ip or CIDR.
+	//Redirects to all hosts are allowed if empty.
+	AllowedHosts []string
+	//RewriteToGet whether 307 and 308 redirects should be followed as GET without body.
+	//Method and body will be kept if false.
+	RewriteToGet bool
+	//SafeHeaders request headers which will be kept when redirecting to another origin.
+	//DefaultRedirectSafeHeaders will be used if nil.
+	SafeHeaders []string
+}
+
+//CheckRedirect check redirect request before following.
+//Headers not in safe headers will be stripped if redirecting to another origin.
+//Could be used as http.Client.CheckRedirect.
+//Return any error if raised.
+func (p *RedirectPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if p.Disable {
+		return http.ErrUseLastResponse
+	}
+	limit := p.MaxRedirects
+	if limit <= 0 {
+		limit = DefaultMaxRedirects
+	}
+	if len(via) > limit {
+		return fmt.Errorf("%w [%d]", ErrTooManyRedirects, limit)
+	}
+	if len(p.AllowedHosts) > 0 && !matchHostPatterns(p.AllowedHosts, req.URL.Host) {
+		return fmt.Errorf("%w [%s]", ErrRedirectNotAllowed, req.URL.Host)
+	}
+	if p.RewriteToGet && req.Response != nil {
+		switch req.Response.StatusCode {
+		case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			req.Method = http.MethodGet
+			req.Body = nil
+			req.GetBody = nil
+			req.ContentLength = 0
+			req.Header.Del("Content-Type")
+			req.Header.Del("Content-Length")
+		}
+	}
+	if len(via) > 0 && requestOrigin(req.URL) != requestOrigin(via[0].URL) {
+		safe := p.SafeHeaders
+		if safe == nil {
+			safe = DefaultRedirectSafeHeaders
+		}
+		allowed := make(map[string]bool, len(safe))
+		for _, v := range safe {
+			allowed[http.CanonicalHeaderKey(v)] = true
+		}
+		for name := range req.Header {
+			if !allowed[http.CanonicalHeaderKey(name)] {
+				delete(req.Header, name)
+			}
+		}
+	}
+	return nil
+}
+
+func requestOrigin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+//RedirectChain return redirect responses which led to response,oldest first.
+//Bodies of redirect responses are already closed.
+//Return nil if response is not redirected.
+func (r *Response) RedirectChain() []*http.Response {
+	var chain []*http.Response
+	if r.Response == nil {
+		return nil
+	}
+	req := r.Response.Request
+	for req != nil && req.Response != nil {
+		chain = append([]*http.Response{req.Response}, chain...)
+		req = req.Response.Request
+	}
+	return chain
+}
@@ -0,0 +1,116 @@
+package fetcher
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newRedirectServer(target string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			http.Redirect(w, r, "/loop?n="+strconv.Itoa(n+1), http.StatusFound)
+		case "/temporary":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/same":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/cross":
+			http.Redirect(w, r, target+"/echo", http.StatusFound)
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(r.Method + "|" + r.Header.Get("Authorization") + "|" + r.Header.Get("X-Token") + "|" + r.Header.Get("Accept") + "|" + string(body)))
+		}
+	}))
+}
+
+func fetchRedirect(t *testing.T, c *Client, cmds ...Command) (*Response, string, error) {
+	resp, err := NewPreset().With(c).With(cmds...).Fetch()
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := resp.BodyContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body), nil
+}
+
+func TestRedirectCredentialStripping(t *testing.T) {
+	other := newRedirectServer("")
+	defer other.Close()
+	server := newRedirectServer(other.URL)
+	defer server.Close()
+	cmds := []Command{BasicAuth("user", "pass"), SetHeader("X-Token", "secret"), SetHeader("Accept", "text/plain")}
+	resp, body, err := fetchRedirect(t, &Client{}, append(cmds, URL(server.URL+"/same"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "GET|Basic dXNlcjpwYXNz|secret|text/plain|" {
+		t.Fatal(body)
+	}
+	chain := resp.RedirectChain()
+	if len(chain) != 1 || chain[0].StatusCode != http.StatusFound || chain[0].Request.URL.Path != "/same" {
+		t.Fatal(chain)
+	}
+	resp, body, err = fetchRedirect(t, &Client{}, append(cmds, URL(server.URL+"/cross"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "GET|||text/plain|" {
+		t.Fatal(body)
+	}
+	if len(resp.RedirectChain()) != 1 {
+		t.Fatal(resp.RedirectChain())
+	}
+	resp, _, err = fetchRedirect(t, &Client{}, URL(server.URL+"/echo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RedirectChain() != nil {
+		t.Fatal(resp.RedirectChain())
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	server := newRedirectServer("http://www.example.com")
+	defer server.Close()
+	resp, _, err := fetchRedirect(t, &Client{DisableRedirect: true}, URL(server.URL+"/same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/echo" {
+		t.Fatal(resp.StatusCode)
+	}
+	_, _, err = fetchRedirect(t, &Client{MaxRedirects: 3}, URL(server.URL+"/loop"))
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatal(err)
+	}
+	_, _, err = fetchRedirect(t, &Client{}, URL(server.URL+"/loop"))
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatal(err)
+	}
+	_, _, err = fetchRedirect(t, &Client{RedirectAllowedHosts: []string{"127.0.0.1"}}, URL(server.URL+"/cross"))
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatal(err)
+	}
+	_, body, err := fetchRedirect(t, &Client{RedirectAllowedHosts: []string{"127.0.0.0/8"}}, URL(server.URL+"/same"))
+	if err != nil || body != "GET||||" {
+		t.Fatal(body, err)
+	}
+	_, body, err = fetchRedirect(t, &Client{}, Post, JSONBody("data"), URL(server.URL+"/temporary"))
+	if err != nil || body != `POST||||"data"` {
+		t.Fatal(body, err)
+	}
+	_, body, err = fetchRedirect(t, &Client{RedirectRewriteToGet: true}, Post, JSONBody("data"), URL(server.URL+"/temporary"))
+	if err != nil || body != "GET||||" {
+		t.Fatal(body, err)
+	}
+	if (&Client{RedirectAllowedHosts: []string{"10.0.0.0/99"}}).SelfCheck() == nil {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,331 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//DefaultRetryMaxAttempts default max attempts of retry policy,include the first attempt.
+var DefaultRetryMaxAttempts = 3
+
+//DefaultRetryStatusCodes default status codes which should be retried.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+//DefaultRetryBackoff default backoff before second attempt.
+var DefaultRetryBackoff = 200 * time.Millisecond
+
+//DefaultRetryMaxBackoff default max backoff between attempts.
+var DefaultRetryMaxBackoff = 30 * time.Second
+
+//DefaultRetryJitter default jitter factor of retry backoff.
+var DefaultRetryJitter = 0.5
+
+//DefaultRetryIdempotentMethods default idempotent methods which can be retried safely.
+var DefaultRetryIdempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+//RetryPolicy retry policy struct which decides whether and when a request should be retried.
+type RetryPolicy struct {
+	//MaxAttempts max attempts,include the first attempt.
+	MaxAttempts int
+	//StatusCodes response status codes which should be retried.
+	StatusCodes []int
+	//RetryNetworkErrors whether network errors should be retried.
+	//Only errors reported by IsNetworkError will be retried.
+	RetryNetworkErrors bool
+	//RetryNonIdempotent whether requests with non-idempotent method like POST and PATCH should be retried.
+	//Requests with Idempotency-Key or X-Idempotency-Key header are always treated as idempotent.
+	RetryNonIdempotent bool
+	//ShouldRetry optional predicate which reports whether response should be retried.
+	//Response body can be read by BodyContent safely.
+	ShouldRetry func(*Response) bool
+	//Backoff backoff before second attempt.
+	//Backoff will be doubled after every attempt.
+	Backoff time.Duration
+	//MaxBackoff max backoff between attempts.
+	//Response with Retry-After header longer than MaxBackoff will not be retried.
+	MaxBackoff time.Duration
+	//Jitter jitter factor between 0 and 1.
+	//Backoff will be randomly reduced by at most Jitter*backoff.
+	Jitter float64
+	//IgnoreRetryAfter whether Retry-After header should be ignored.
+	IgnoreRetryAfter bool
+}
+
+//NewRetryPolicy create new retry policy with default values.
+func NewRetryPolicy() *RetryPolicy {
+	codes := make([]int, len(DefaultRetryStatusCodes))
+	copy(codes, DefaultRetryStatusCodes)
+	return &RetryPolicy{
+		MaxAttempts:        DefaultRetryMaxAttempts,
+		StatusCodes:        codes,
+		RetryNetworkErrors: true,
+		Backoff:            DefaultRetryBackoff,
+		MaxBackoff:         DefaultRetryMaxBackoff,
+		Jitter:             DefaultRetryJitter,
+	}
+}
+
+//IsNetworkError check if given error is a network error which could be retried.
+//Context cancellation,redirect policy rejections and certificate errors are not network errors.
+func IsNetworkError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var uerr *url.Error
+	for errors.As(err, &uerr) {
+		err = uerr.Err
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+//RetryableRequest check if given request could be retried by request method.
+func (p *RetryPolicy) RetryableRequest(req *http.Request) bool {
+	if p.RetryNonIdempotent {
+		return true
+	}
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return true
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	for _, v := range DefaultRetryIdempotentMethods {
+		if method == v {
+			return true
+		}
+	}
+	return false
+}
+
+//Retryable check if given response and error should be retried.
+func (p *RetryPolicy) Retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return p.RetryNetworkErrors && IsNetworkError(err)
+	}
+	for _, v := range p.StatusCodes {
+		if resp.StatusCode == v {
+			return true
+		}
+	}
+	if p.ShouldRetry != nil {
+		r := ConvertResponse(resp)
+		result := p.ShouldRetry(r)
+		if r.bytes != nil {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(*r.bytes))
+		}
+		return result
+	}
+	return false
+}
+
+//Delay return delay before next attempt.
+//Attempt is the number of attempts already done.
+//Return delay and whether request should be retried.
+func (p *RetryPolicy) Delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	delay := p.Backoff
+	for i := 1; i < attempt; i++ {
+		delay = delay * 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 && delay > 0 {
+		delay = delay - time.Duration(rand.Float64()*p.Jitter*float64(delay))
+	}
+	if resp != nil && !p.IgnoreRetryAfter {
+		after, ok := ParseRetryAfter(resp.Header.Get("Retry-After"))
+		if ok {
+			if p.MaxBackoff > 0 && after > p.MaxBackoff {
+				return 0, false
+			}
+			if after > delay {
+				delay = after
+			}
+		}
+	}
+	return delay, true
+}
+
+//ParseRetryAfter parse Retry-After header value in seconds or http date format.
+//Return duration and whether value is valid.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+//RetryDoer doer which retries failed requests by given policy.
+//Request with body will be retried only if request GetBody is setted.
+//Request with non-idempotent method will be retried only if policy RetryNonIdempotent is true.
+type RetryDoer struct {
+	//Doer doer by which requests will be done.
+	Doer Doer
+	//Policy retry policy
+	Policy *RetryPolicy
+}
+
+//NewRetryDoer create new retry doer with given doer and policy.
+//Default policy will be used if policy is nil.
+func NewRetryDoer(d Doer, p *RetryPolicy) *RetryDoer {
+	if p == nil {
+		p = NewRetryPolicy()
+	}
+	return &RetryDoer{
+		Doer:   d,
+		Policy: p,
+	}
+}
+
+//Do do http request with retry.
+//Return http response and any error if raised.
+func (d *RetryDoer) Do(req *http.Request) (*http.Response, error) {
+	if !d.Policy.RetryableRequest(req) {
+		return d.Doer.Do(req)
+	}
+	ctx := req.Context()
+	current := req
+	for attempt := 1; ; attempt++ {
+		resp, err := d.Doer.Do(current)
+		if attempt >= d.Policy.MaxAttempts || ctx.Err() != nil || !d.Policy.Retryable(resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+		delay, ok := d.Policy.Delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		next := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, berr := req.GetBody()
+			if berr != nil {
+				return resp, err
+			}
+			next.Body = body
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		current = next
+	}
+}
+
+//RetryConfig serializable retry config struct.
+type RetryConfig struct {
+	//MaxAttempts max attempts,include the first attempt.
+	//Default value is 3.
+	MaxAttempts int
+	//StatusCodes response status codes which should be retried.
+	//Default value is 429,502,503,504.
+	StatusCodes []int
+	//BackoffInMillisecond backoff before second attempt in millisecond.
+	//Default value is 200.
+	BackoffInMillisecond int64
+	//MaxBackoffInMillisecond max backoff between attempts in millisecond.
+	//Default value is 30000.
+	MaxBackoffInMillisecond int64
+	//DisableNetworkErrorRetry whether network errors should not be retried.
+	DisableNetworkErrorRetry bool
+	//RetryNonIdempotent whether requests with non-idempotent method like POST and PATCH should be retried.
+	RetryNonIdempotent bool
+	//DisableJitter whether backoff jitter should be disabled.
+	DisableJitter bool
+	//IgnoreRetryAfter whether Retry-After header should be ignored.
+	IgnoreRetryAfter bool
+}
+
+//Clone clone a new retry config.
+func (c *RetryConfig) Clone() *RetryConfig {
+	if c == nil {
+		return nil
+	}
+	cloned := *c
+	if c.StatusCodes != nil {
+		cloned.StatusCodes = make([]int, len(c.StatusCodes))
+		copy(cloned.StatusCodes, c.StatusCodes)
+	}
+	return &cloned
+}
+
+//CreatePolicy create retry policy by config.
+func (c *RetryConfig) CreatePolicy() *RetryPolicy {
+	p := NewRetryPolicy()
+	if c.MaxAttempts > 0 {
+		p.MaxAttempts = c.MaxAttempts
+	}
+	if len(c.StatusCodes) > 0 {
+		p.StatusCodes = make([]int, len(c.StatusCodes))
+		copy(p.StatusCodes, c.StatusCodes)
+	}
+	if c.BackoffInMillisecond > 0 {
+		p.Backoff = time.Duration(c.BackoffInMillisecond) * time.Millisecond
+	}
+	if c.MaxBackoffInMillisecond > 0 {
+		p.MaxBackoff = time.Duration(c.MaxBackoffInMillisecond) * time.Millisecond
+	}
+	p.RetryNetworkErrors = !c.DisableNetworkErrorRetry
+	p.RetryNonIdempotent = c.RetryNonIdempotent
+	if c.DisableJitter {
+		p.Jitter = 0
+	}
+	p.IgnoreRetryAfter = c.IgnoreRetryAfter
+	return p
+}
@@ -0,0 +1,264 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newFlakyServer(failures int32, statuscode int, header http.Header) (*httptest.Server, *int32) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		if n <= failures {
+			for k := range header {
+				w.Header()[k] = header[k]
+			}
+			w.WriteHeader(statuscode)
+			w.Write([]byte("failed"))
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		w.Write(data)
+	}))
+	return s, &count
+}
+
+func newTestRetryPolicy() *RetryPolicy {
+	p := NewRetryPolicy()
+	p.Backoff = time.Millisecond
+	p.Jitter = 0
+	return p
+}
+
+func TestRetryDoer(t *testing.T) {
+	s, count := newFlakyServer(2, 503, nil)
+	defer s.Close()
+	doer := NewRetryDoer(http.DefaultClient, newTestRetryPolicy())
+	var result string
+	_, err := DoWithBodyAndParse(doer, MustPreset(&ServerInfo{URL: s.URL, Method: "POST"}), bytes.NewBufferString("body"), Should200(AsString(&result)))
+	if !CompareResponseErrStatusCode(err, 503) || atomic.LoadInt32(count) != 1 {
+		t.Fatal(err, *count)
+	}
+	s, count = newFlakyServer(2, 503, nil)
+	defer s.Close()
+	_, err = DoAndParse(doer, MustPreset(&ServerInfo{URL: s.URL, Method: "PUT"}).With(SetHeader("X-Idempotency-Key", "key"), Body(bytes.NewBufferString("body"))), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(count) != 3 {
+		t.Fatal(*count)
+	}
+	s, count = newFlakyServer(2, 503, nil)
+	defer s.Close()
+	_, err = DoAndParse(doer, MustPreset(&ServerInfo{URL: s.URL, Method: "PATCH"}).With(SetHeader("Idempotency-Key", "key"), StringBody("body")), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "body" || atomic.LoadInt32(count) != 3 {
+		t.Fatal(result, *count)
+	}
+	s, count = newFlakyServer(2, 503, nil)
+	defer s.Close()
+	doer.Policy.RetryNonIdempotent = true
+	_, err = DoAndParse(doer, MustPreset(&ServerInfo{URL: s.URL, Method: "POST"}).With(StringBody("body")), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "body" || atomic.LoadInt32(count) != 3 {
+		t.Fatal(result, *count)
+	}
+
+	s, count = newFlakyServer(5, 502, nil)
+	defer s.Close()
+	resp, err := DoAndParse(doer, MustPreset(&ServerInfo{URL: s.URL}), Should200(nil))
+	if !CompareResponseErrStatusCode(err, 502) {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(count) != 3 || resp.StatusCode != 502 {
+		t.Fatal(*count)
+	}
+
+	s, count = newFlakyServer(5, 500, nil)
+	defer s.Close()
+	_, err = DoAndParse(doer, MustPreset(&ServerInfo{URL: s.URL}), Should200(nil))
+	if !CompareResponseErrStatusCode(err, 500) || atomic.LoadInt32(count) != 1 {
+		t.Fatal(err, *count)
+	}
+}
+
+func TestRetryPredicate(t *testing.T) {
+	s, count := newFlakyServer(1, 200, nil)
+	defer s.Close()
+	p := newTestRetryPolicy()
+	p.RetryNonIdempotent = true
+	p.ShouldRetry = func(resp *Response) bool {
+		bs, err := resp.BodyContent()
+		return err == nil && string(bs) == "failed"
+	}
+	var result string
+	_, err := DoWithBodyAndParse(NewRetryDoer(http.DefaultClient, p), MustPreset(&ServerInfo{URL: s.URL, Method: "POST"}), bytes.NewBufferString("ok"), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "ok" || atomic.LoadInt32(count) != 2 {
+		t.Fatal(result, *count)
+	}
+	p.MaxAttempts = 1
+	s, count = newFlakyServer(1, 200, nil)
+	defer s.Close()
+	_, err = DoAndParse(NewRetryDoer(http.DefaultClient, p), MustPreset(&ServerInfo{URL: s.URL}), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "failed" || atomic.LoadInt32(count) != 1 {
+		t.Fatal(result, *count)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	d, ok := ParseRetryAfter("2")
+	if !ok || d != 2*time.Second {
+		t.Fatal(d, ok)
+	}
+	d, ok = ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || d <= 59*time.Minute {
+		t.Fatal(d, ok)
+	}
+	_, ok = ParseRetryAfter("invalid")
+	if ok {
+		t.Fatal(ok)
+	}
+	header := http.Header{}
+	header.Set("Retry-After", "3600")
+	s, count := newFlakyServer(1, 429, header)
+	defer s.Close()
+	_, err := DoAndParse(NewRetryDoer(http.DefaultClient, newTestRetryPolicy()), MustPreset(&ServerInfo{URL: s.URL}), Should200(nil))
+	if !CompareResponseErrStatusCode(err, 429) || atomic.LoadInt32(count) != 1 {
+		t.Fatal(err, *count)
+	}
+	p := newTestRetryPolicy()
+	delay, ok := p.Delay(1, &http.Response{Header: http.Header{"Retry-After": []string{"1"}}})
+	if !ok || delay != time.Second {
+		t.Fatal(delay, ok)
+	}
+	delay, ok = p.Delay(3, nil)
+	if !ok || delay != 4*time.Millisecond {
+		t.Fatal(delay, ok)
+	}
+}
+
+type errDoer struct {
+	count int
+}
+
+func (d *errDoer) Do(req *http.Request) (*http.Response, error) {
+	d.count++
+	return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	var cases = map[error]bool{
+		nil:                      false,
+		errors.New("error"):      false,
+		context.Canceled:         false,
+		context.DeadlineExceeded: false,
+		&url.Error{Op: "Get", Err: context.Canceled}:             false,
+		&url.Error{Op: "Get", Err: ErrTooManyRedirects}:          false,
+		&url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}: false,
+		&url.Error{Op: "Get", Err: errors.New("proxy error")}:    false,
+		&url.Error{Op: "Get", Err: io.EOF}:                       true,
+		fmt.Errorf("read:%w", syscall.ECONNRESET):                true,
+		&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}:      true,
+		&net.DNSError{Err: "no such host", Name: "invalid"}:      true,
+	}
+	for err, expected := range cases {
+		if IsNetworkError(err) != expected {
+			t.Fatal(err, expected)
+		}
+	}
+	d := &errDoer{}
+	p := newTestRetryPolicy()
+	canceled := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		d.count++
+		return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: context.Canceled}
+	})
+	_, err := DoAndParse(NewRetryDoer(canceled, p), MustPreset(&ServerInfo{URL: "http://127.0.0.1"}), nil)
+	if !errors.Is(err, context.Canceled) || d.count != 1 {
+		t.Fatal(err, d.count)
+	}
+	d.count = 0
+	_, err = DoAndParse(NewRetryDoer(d, p), MustPreset(&ServerInfo{URL: "http://127.0.0.1", Method: "POST"}), nil)
+	if err == nil || d.count != 1 {
+		t.Fatal(err, d.count)
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	d := &errDoer{}
+	_, err := DoAndParse(NewRetryDoer(d, newTestRetryPolicy()), MustPreset(&ServerInfo{URL: "http://127.0.0.1"}), nil)
+	if err == nil || d.count != 3 {
+		t.Fatal(err, d.count)
+	}
+	d = &errDoer{}
+	p := newTestRetryPolicy()
+	p.RetryNetworkErrors = false
+	_, err = DoAndParse(NewRetryDoer(d, p), MustPreset(&ServerInfo{URL: "http://127.0.0.1"}), nil)
+	if err == nil || d.count != 1 {
+		t.Fatal(err, d.count)
+	}
+	d = &errDoer{}
+	p = newTestRetryPolicy()
+	p.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = DoAndParseContext(ctx, NewRetryDoer(d, p), MustPreset(&ServerInfo{URL: "http://127.0.0.1"}), nil)
+	if err != context.DeadlineExceeded || d.count != 1 {
+		t.Fatal(err, d.count)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	s, count := newFlakyServer(1, 504, nil)
+	defer s.Close()
+	server := &Server{
+		ServerInfo: ServerInfo{URL: s.URL},
+		Client: Client{
+			Retry: &RetryConfig{
+				BackoffInMillisecond: 1,
+				StatusCodes:          []int{504},
+			},
+		},
+	}
+	_, err := MustPreset(server).FetchAndParse(Should200(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(count) != 2 {
+		t.Fatal(*count)
+	}
+	cloned := server.Client.Clone()
+	cloned.Retry.StatusCodes[0] = 503
+	if server.Client.Retry.StatusCodes[0] != 504 {
+		t.Fatal(cloned)
+	}
+	p := (&RetryConfig{}).CreatePolicy()
+	if p.MaxAttempts != DefaultRetryMaxAttempts || !p.RetryNetworkErrors || p.RetryNonIdempotent || p.Jitter != DefaultRetryJitter {
+		t.Fatal(p)
+	}
+}
@@ -0,0 +1,206 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socks5Version      = 0x05
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+	socks5CmdConnect   = 0x01
+	socks5AtypIPv4     = 0x01
+	socks5AtypDomain   = 0x03
+	socks5AtypIPv6     = 0x04
+)
+
+//DialContextFunc dial context func type
+type DialContextFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+//SOCKS5Dialer dialer which connects to address through SOCKS5 proxy.
+type SOCKS5Dialer struct {
+	//ProxyAddr SOCKS5 proxy address in "host:port" format.
+	ProxyAddr string
+	//Username proxy username.
+	//Username/password authentication will be used if not empty.
+	Username string
+	//Password proxy password.
+	Password string
+	//RemoteResolve whether target host name should be resolved by proxy.
+	RemoteResolve bool
+	//Forward dial func used to connect to proxy.
+	//net.Dialer will be used if nil.
+	Forward DialContextFunc
+}
+
+//DialContext connect to given address through SOCKS5 proxy.
+//Return connection and any error if raised.
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("fetcher:socks5 network %q not supported", network)
+	}
+	forward := d.Forward
+	if forward == nil {
+		forward = (&net.Dialer{}).DialContext
+	}
+	conn, err := forward(ctx, "tcp", d.ProxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	err = d.handshake(ctx, conn, addr)
+	close(done)
+	<-exited
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+				return nil, context.DeadlineExceeded
+			}
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *SOCKS5Dialer) handshake(ctx context.Context, conn net.Conn, addr string) error {
+	host, portstr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("fetcher:invalid port %q", portstr)
+	}
+	method := byte(socks5AuthNone)
+	if d.Username != "" {
+		method = socks5AuthPassword
+	}
+	_, err = conn.Write([]byte{socks5Version, 1, method})
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return err
+	}
+	if buf[0] != socks5Version {
+		return errors.New("fetcher:invalid socks5 proxy response")
+	}
+	if buf[1] != method {
+		return errors.New("fetcher:socks5 proxy authentication method not acceptable")
+	}
+	if method == socks5AuthPassword {
+		if len(d.Username) > 255 || len(d.Password) > 255 {
+			return errors.New("fetcher:socks5 username or password too long")
+		}
+		req := []byte{0x01, byte(len(d.Username))}
+		req = append(req, d.Username...)
+		req = append(req, byte(len(d.Password)))
+		req = append(req, d.Password...)
+		_, err = conn.Write(req)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			return err
+		}
+		if buf[1] != 0x00 {
+			return errors.New("fetcher:socks5 proxy authentication failed")
+		}
+	}
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && !d.RemoteResolve {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("fetcher:no address found for %q", host)
+		}
+		ip = addrs[0].IP
+	}
+	if ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AtypIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AtypIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("fetcher:host name too long %q", host)
+		}
+		req = append(req, socks5AtypDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+	reply := make([]byte, 4)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return errors.New("fetcher:invalid socks5 proxy response")
+	}
+	if reply[1] != 0x00 {
+		return fmt.Errorf("fetcher:socks5 proxy connect failed with code %d", reply[1])
+	}
+	var skip int
+	switch reply[3] {
+	case socks5AtypIPv4:
+		skip = net.IPv4len
+	case socks5AtypIPv6:
+		skip = net.IPv6len
+	case socks5AtypDomain:
+		l := make([]byte, 1)
+		_, err = io.ReadFull(conn, l)
+		if err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return errors.New("fetcher:invalid socks5 proxy response")
+	}
+	bound := make([]byte, skip+2)
+	_, err = io.ReadFull(conn, bound)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSOCKS5Server struct {
+	listener net.Listener
+	username string
+	password string
+	locker   sync.Mutex
+	targets  []string
+}
+
+func newTestSOCKS5Server(t *testing.T, username string, password string) *testSOCKS5Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSOCKS5Server{listener: l, username: username, password: password}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSOCKS5Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testSOCKS5Server) Close() {
+	s.listener.Close()
+}
+
+func (s *testSOCKS5Server) Targets() []string {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return append([]string(nil), s.targets...)
+}
+
+func (s *testSOCKS5Server) serve(conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	method := byte(socks5AuthNone)
+	if s.username != "" {
+		method = socks5AuthPassword
+	}
+	accepted := false
+	for _, m := range methods {
+		if m == method {
+			accepted = true
+		}
+	}
+	if !accepted {
+		conn.Write([]byte{socks5Version, 0xff})
+		return
+	}
+	conn.Write([]byte{socks5Version, method})
+	if method == socks5AuthPassword {
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		username := make([]byte, buf[1])
+		if _, err := io.ReadFull(conn, username); err != nil {
+			return
+		}
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return
+		}
+		password := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, password); err != nil {
+			return
+		}
+		if string(username) != s.username || string(password) != s.password {
+			conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		conn.Write([]byte{0x01, 0x00})
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	var host string
+	switch header[3] {
+	case socks5AtypIPv4:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case socks5AtypIPv6:
+		ip := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case socks5AtypDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	s.locker.Lock()
+	s.targets = append(s.targets, target)
+	s.locker.Unlock()
+	if host == "localhost" {
+		target = net.JoinHostPort("127.0.0.1", strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	}
+	remote, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{socks5Version, 0x05, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer remote.Close()
+	conn.Write([]byte{socks5Version, 0x00, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+	go io.Copy(remote, conn)
+	io.Copy(conn, remote)
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(EchoAction))
+	defer server.Close()
+	proxy := newTestSOCKS5Server(t, "user", "pass")
+	defer proxy.Close()
+	addr := server.Listener.Addr().String()
+	d := &SOCKS5Dialer{ProxyAddr: proxy.Addr(), Username: "user", Password: "pass"}
+	conn, err := d.DialContext(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	d.Password = "wrong"
+	_, err = d.DialContext(context.Background(), "tcp", addr)
+	if err == nil {
+		t.Fatal(err)
+	}
+	d.Username = ""
+	_, err = d.DialContext(context.Background(), "tcp", addr)
+	if err == nil {
+		t.Fatal(err)
+	}
+	_, err = d.DialContext(context.Background(), "udp", addr)
+	if err == nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = (&SOCKS5Dialer{ProxyAddr: proxy.Addr()}).DialContext(ctx, "tcp", addr)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSOCKS5DialerTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(time.Second)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = (&SOCKS5Dialer{ProxyAddr: l.Addr().String()}).DialContext(ctx, "tcp", "127.0.0.1:80")
+	if err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+func TestClientSOCKS5Proxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(EchoAction))
+	defer server.Close()
+	proxy := newTestSOCKS5Server(t, "user", "pass")
+	defer proxy.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{
+		Proxy:         "socks5h://" + proxy.Addr(),
+		ProxyUsername: "user",
+		ProxyPassword: "pass",
+	}
+	resp, err := client.Do(mustNewRequest(t, "http://localhost:"+port+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatal(resp.StatusCode)
+	}
+	targets := proxy.Targets()
+	if len(targets) != 1 || targets[0] != "localhost:"+port {
+		t.Fatal(targets)
+	}
+	client = &Client{
+		Proxy:   "socks5://user:pass@" + proxy.Addr(),
+		NoProxy: []string{"localhost"},
+	}
+	resp, err = client.Do(mustNewRequest(t, "http://localhost:"+port+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(proxy.Targets()) != 1 {
+		t.Fatal(proxy.Targets())
+	}
+	client = &Client{
+		Proxy: "socks5://user:wrong@" + proxy.Addr(),
+	}
+	_, err = client.Do(mustNewRequest(t, server.URL))
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func mustNewRequest(t *testing.T, u string) *http.Request {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
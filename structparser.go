@@ -0,0 +1,237 @@
+package fetcher
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	typeTime     = reflect.TypeOf(time.Time{})
+	typeDuration = reflect.TypeOf(time.Duration(0))
+	typeCookie   = reflect.TypeOf(http.Cookie{})
+)
+
+//AsStruct create parser which parse response into given struct pointer by struct tags.
+//Tag header:"X-Total-Count" copies header value,slice field will get all values.
+//Tag status:"" copies status code into int field,or status line into string field.
+//Tag body:"json" or body:"xml" decodes response body into field.
+//Tag cookie:"session" copies cookie value,or whole cookie into http.Cookie field.
+//Fields without tags and missing headers or cookies will be left unchanged.
+//Field values are parsed in reverse of EncodeForm,including "layout" tag for time.Time.
+//Embedded structs without tags will be flattened.
+//Response body will be read and closed.
+func AsStruct(v interface{}) Parser {
+	return ParserFunc(func(resp *Response) error {
+		bs, err := resp.BodyContent()
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("fetcher:struct parser requires non-nil struct pointer,got %T", v)
+		}
+		return parseStruct(resp, bs, rv.Elem())
+	})
+}
+
+var structParserTags = []string{"header", "status", "body", "cookie"}
+
+func parseStruct(resp *Response, bs []byte, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		tagged := false
+		for _, tagname := range structParserTags {
+			tag, ok := field.Tag.Lookup(tagname)
+			if !ok || tag == "-" {
+				continue
+			}
+			tagged = true
+			if field.PkgPath != "" {
+				continue
+			}
+			err := parseStructField(resp, bs, tagname, tag, field, fv)
+			if err != nil {
+				return fmt.Errorf("fetcher:field %s:%w", field.Name, err)
+			}
+		}
+		if tagged || !field.Anonymous {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				if !fv.CanSet() {
+					continue
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			err := parseStruct(resp, bs, fv)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseStructField(resp *Response, bs []byte, tagname string, tag string, field reflect.StructField, fv reflect.Value) error {
+	name, _ := parseTag(tag)
+	layout := field.Tag.Get("layout")
+	switch tagname {
+	case "header":
+		if name == "" {
+			return fmt.Errorf("fetcher:empty header tag name")
+		}
+		values := resp.Header.Values(name)
+		if len(values) == 0 {
+			return nil
+		}
+		return parseValues(fv, values, layout)
+	case "status":
+		if fv.Kind() == reflect.String {
+			fv.SetString(resp.Status)
+			return nil
+		}
+		return parseValues(fv, []string{strconv.Itoa(resp.StatusCode)}, layout)
+	case "body":
+		switch name {
+		case "json":
+			return json.Unmarshal(bs, fv.Addr().Interface())
+		case "xml":
+			return xml.Unmarshal(bs, fv.Addr().Interface())
+		}
+		return fmt.Errorf("fetcher:unsupported body format %q", name)
+	case "cookie":
+		if name == "" {
+			return fmt.Errorf("fetcher:empty cookie tag name")
+		}
+		for _, c := range resp.Cookies() {
+			if c.Name != name {
+				continue
+			}
+			t := fv.Type()
+			if t == typeCookie {
+				fv.Set(reflect.ValueOf(*c))
+				return nil
+			}
+			if t.Kind() == reflect.Ptr && t.Elem() == typeCookie {
+				fv.Set(reflect.ValueOf(c))
+				return nil
+			}
+			return parseValues(fv, []string{c.Value}, layout)
+		}
+	}
+	return nil
+}
+
+func parseValues(rv reflect.Value, values []string, layout string) error {
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		result := reflect.MakeSlice(rv.Type(), len(values), len(values))
+		for k, v := range values {
+			err := parseValue(result.Index(k), v, layout)
+			if err != nil {
+				return err
+			}
+		}
+		rv.Set(result)
+		return nil
+	}
+	return parseValue(rv, values[0], layout)
+}
+
+func parseValue(rv reflect.Value, value string, layout string) error {
+	if rv.Kind() == reflect.Ptr {
+		v := reflect.New(rv.Type().Elem())
+		err := parseValue(v.Elem(), value, layout)
+		if err != nil {
+			return err
+		}
+		rv.Set(v)
+		return nil
+	}
+	switch rv.Type() {
+	case typeTime:
+		t, err := parseTime(value, layout)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case typeDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+	if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(value)
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes([]byte(value))
+			return nil
+		}
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(value, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(i)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("fetcher:unsupported type %s", rv.Type())
+}
+
+func parseTime(value string, layout string) (time.Time, error) {
+	switch layout {
+	case "":
+		return time.Parse(time.RFC3339, value)
+	case "unix", "unixmilli":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if layout == "unix" {
+			return time.Unix(i, 0), nil
+		}
+		return time.Unix(0, i*int64(time.Millisecond)), nil
+	}
+	return time.Parse(layout, value)
+}
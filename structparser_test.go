@@ -0,0 +1,128 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testStructLevel int
+
+func (l *testStructLevel) UnmarshalText(data []byte) error {
+	*l = testStructLevel(len(data))
+	return nil
+}
+
+type testStructPage struct {
+	Total int `header:"X-Total-Count"`
+}
+
+type testStructResult struct {
+	testStructPage
+	Status      int             `status:""`
+	StatusLine  string          `status:""`
+	Links       []string        `header:"Link"`
+	Modified    time.Time       `header:"Last-Modified" layout:"Mon, 02 Jan 2006 15:04:05 GMT"`
+	Expires     *time.Time      `header:"X-Expires" layout:"unix"`
+	Timeout     time.Duration   `header:"X-Timeout"`
+	Level       testStructLevel `header:"X-Level"`
+	Ratio       float64         `header:"X-Ratio"`
+	Missing     string          `header:"X-Missing"`
+	Session     string          `cookie:"session"`
+	Cookie      *http.Cookie    `cookie:"session"`
+	CookieValue http.Cookie     `cookie:"other"`
+	Items       []string        `body:"json"`
+	Plain       string
+}
+
+func TestAsStruct(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "42")
+		w.Header().Add("Link", "<a>")
+		w.Header().Add("Link", "<b>")
+		w.Header().Set("Last-Modified", "Thu, 02 Jan 2020 03:04:05 GMT")
+		w.Header().Set("X-Expires", "1578009600")
+		w.Header().Set("X-Timeout", "1m")
+		w.Header().Set("X-Level", "level")
+		w.Header().Set("X-Ratio", "0.5")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "sid", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "other", Value: "value"})
+		if r.URL.Path == "/xml" {
+			w.Write([]byte("<result><name>xml</name></result>"))
+			return
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(`["a","b"]`))
+	}))
+	defer server.Close()
+	result := &testStructResult{Missing: "unchanged", Plain: "plain"}
+	_, err := FetchAndParse(NewPreset().With(URL(server.URL)), Should200(AsStruct(result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 42 || result.Status != 200 || result.StatusLine != "200 OK" || len(result.Links) != 2 || result.Links[1] != "<b>" {
+		t.Fatal(result)
+	}
+	if !result.Modified.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) || result.Expires == nil || result.Expires.Unix() != 1578009600 {
+		t.Fatal(result.Modified, result.Expires)
+	}
+	if result.Timeout != time.Minute || result.Level != 5 || result.Ratio != 0.5 || result.Missing != "unchanged" || result.Plain != "plain" {
+		t.Fatal(result)
+	}
+	if result.Session != "sid" || result.Cookie == nil || result.Cookie.Path != "/" || result.CookieValue.Value != "value" {
+		t.Fatal(result)
+	}
+	if strings.Join(result.Items, ",") != "a,b" {
+		t.Fatal(result.Items)
+	}
+	xmlresult := &struct {
+		Body struct {
+			Name string `xml:"name"`
+		} `body:"xml"`
+	}{}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL+"/xml")), ShouldSuccess(AsStruct(xmlresult)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xmlresult.Body.Name != "xml" {
+		t.Fatal(xmlresult)
+	}
+	resp, err := FetchAndParse(NewPreset().With(URL(server.URL+"/fail")), Should200(AsStruct(result)))
+	if err == nil || !CompareResponseErrStatusCode(err, http.StatusBadRequest) {
+		t.Fatal(resp, err)
+	}
+}
+
+func TestAsStructErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Count", "notint")
+		w.Write([]byte("notjson"))
+	}))
+	defer server.Close()
+	var cases = []interface{}{
+		struct{}{},
+		(*struct{})(nil),
+		&struct {
+			Count int `header:"X-Count"`
+		}{},
+		&struct {
+			Body []string `body:"json"`
+		}{},
+		&struct {
+			Body string `body:"yaml"`
+		}{},
+		&struct {
+			Count map[string]string `header:"X-Count"`
+		}{},
+	}
+	for _, v := range cases {
+		_, err := FetchAndParse(NewPreset().With(URL(server.URL)), AsStruct(v))
+		if err == nil {
+			t.Fatal(v)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+//ParseTLSVersion parse tls version string like "1.2" to tls version.
+//Return tls version and any error if raised.
+func ParseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("fetcher:invalid tls version %q", version)
+	}
+	return v, nil
+}
+
+func (c *Client) hasTLSConfig() bool {
+	return c.TLSCAFile != "" || c.TLSCA != "" || c.TLSCertFile != "" || c.TLSKeyFile != "" || c.TLSMinVersion != "" || c.TLSServerName != "" || c.TLSInsecureSkipVerify
+}
+
+//CreateTLSConfig create tls config by client tls fields.
+//Return nil if no tls field setted.
+//Return tls config created and any error if raised.
+func (c *Client) CreateTLSConfig() (*tls.Config, error) {
+	if !c.hasTLSConfig() {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+	if c.TLSMinVersion != "" {
+		v, err := ParseTLSVersion(c.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		config.MinVersion = v
+	}
+	if c.TLSCAFile != "" || c.TLSCA != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if c.TLSCAFile != "" {
+			data, err := ioutil.ReadFile(c.TLSCAFile)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("fetcher:no certificate found in ca file %q", c.TLSCAFile)
+			}
+		}
+		if c.TLSCA != "" && !pool.AppendCertsFromPEM([]byte(c.TLSCA)) {
+			return nil, errors.New("fetcher:no certificate found in ca pem")
+		}
+		config.RootCAs = pool
+	}
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+			return nil, errors.New("fetcher:tls cert file and key file must be setted together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,139 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPEM(t *testing.T, dir string, s *httptest.Server) (string, string) {
+	cert := s.TLS.Certificates[0]
+	certfile := filepath.Join(dir, "cert.pem")
+	keyfile := filepath.Join(dir, "key.pem")
+	err := ioutil.WriteFile(certfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyfile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certfile, keyfile
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := ParseTLSVersion("1.2")
+	if err != nil || v != tls.VersionTLS12 {
+		t.Fatal(v, err)
+	}
+	_, err = ParseTLSVersion("1.4")
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchertls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(EchoAction))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	defer s.Close()
+	certfile, keyfile := writeTestPEM(t, dir, s)
+	certdata, err := ioutil.ReadFile(certfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases = []*Client{
+		{TLSCAFile: certfile, TLSCertFile: certfile, TLSKeyFile: keyfile, TLSMinVersion: "1.2"},
+		{TLSCA: string(certdata), TLSCertFile: certfile, TLSKeyFile: keyfile, TLSServerName: "example.com"},
+		{TLSInsecureSkipVerify: true, TLSCertFile: certfile, TLSKeyFile: keyfile},
+	}
+	for _, c := range cases {
+		_, err = MustPreset(&Server{ServerInfo: ServerInfo{URL: s.URL}, Client: *c.Clone()}).FetchAndParse(Should200(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var failed = []*Client{
+		{TLSCAFile: certfile},
+		{},
+		{TLSCA: string(certdata), TLSCertFile: certfile, TLSKeyFile: keyfile, TLSServerName: "invalid.test"},
+	}
+	for _, c := range failed {
+		_, err = MustPreset(&Server{ServerInfo: ServerInfo{URL: s.URL}, Client: *c.Clone()}).FetchAndParse(Should200(nil))
+		if err == nil {
+			t.Fatal(c)
+		}
+	}
+	var invalid = []*Client{
+		{TLSCAFile: filepath.Join(dir, "notexist.pem")},
+		{TLSCAFile: keyfile},
+		{TLSCA: "invalid"},
+		{TLSCertFile: certfile},
+		{TLSCertFile: certfile, TLSKeyFile: certfile},
+		{TLSMinVersion: "invalid"},
+	}
+	for _, c := range invalid {
+		if c.SelfCheck() == nil {
+			t.Fatal(c)
+		}
+	}
+	cloned := cases[0].Clone()
+	if cloned.TLSCAFile != certfile || cloned.TLSKeyFile != keyfile || cloned.TLSMinVersion != "1.2" {
+		t.Fatal(cloned)
+	}
+}
+
+func TestClientTLSFileRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetchertls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := httptest.NewTLSServer(http.HandlerFunc(EchoAction))
+	defer s.Close()
+	certfile, keyfile := writeTestPEM(t, dir, s)
+	c := &Client{TLSCAFile: certfile, TLSCertFile: certfile, TLSKeyFile: keyfile}
+	defer c.Close()
+	err = c.SelfCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := c.transportKey()
+	if key != c.Clone().transportKey() {
+		t.Fatal(key)
+	}
+	err = ioutil.WriteFile(certfile, []byte("rotated"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.transportKey() == key {
+		t.Fatal(key)
+	}
+	err = c.Reload(c.Clone())
+	if err == nil {
+		t.Fatal(err)
+	}
+	writeTestPEM(t, dir, s)
+	if c.transportKey() != key {
+		t.Fatal(key)
+	}
+	err = c.Reload(c.Clone())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,312 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//TransportPoolStats transport pool statistics.
+type TransportPoolStats struct {
+	//Transports count of transports in pool.
+	Transports int
+	//References count of references held on transports in pool.
+	References int
+	//Created count of transports created by pool.
+	Created int64
+	//Reused count of acquisitions which reused existing transport.
+	Reused int64
+	//Released count of transports released from pool.
+	Released int64
+}
+
+type transportPoolEntry struct {
+	transport *http.Transport
+	refs      int
+}
+
+//TransportPool reference counted transport registry.
+//Transports are shared by key,so that clients with identical settings share connections.
+type TransportPool struct {
+	locker  sync.Mutex
+	entries map[string]*transportPoolEntry
+	stats   TransportPoolStats
+}
+
+//NewTransportPool create new transport pool.
+func NewTransportPool() *TransportPool {
+	return &TransportPool{
+		entries: map[string]*transportPoolEntry{},
+	}
+}
+
+//DefaultTransportPool process-wide transport pool used by Client.
+//Transports will not be shared if set to nil.
+var DefaultTransportPool = NewTransportPool()
+
+//Acquire acquire a reference on transport with given key.
+//Transport will be created by given create func if not exists.
+//Return transport and any error if raised.
+func (p *TransportPool) Acquire(key string, create func() (*http.Transport, error)) (*http.Transport, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	e, ok := p.entries[key]
+	if ok {
+		e.refs++
+		p.stats.Reused++
+		return e.transport, nil
+	}
+	t, err := create()
+	if err != nil {
+		return nil, err
+	}
+	p.entries[key] = &transportPoolEntry{transport: t, refs: 1}
+	p.stats.Created++
+	return t, nil
+}
+
+//Release release a reference on transport with given key.
+//Transport will be removed from pool and its idle connections closed when no reference left.
+func (p *TransportPool) Release(key string) {
+	p.locker.Lock()
+	e, ok := p.entries[key]
+	if !ok {
+		p.locker.Unlock()
+		return
+	}
+	e.refs--
+	if e.refs > 0 {
+		p.locker.Unlock()
+		return
+	}
+	delete(p.entries, key)
+	p.stats.Released++
+	p.locker.Unlock()
+	e.transport.CloseIdleConnections()
+}
+
+//Stats return pool statistics.
+func (p *TransportPool) Stats() TransportPoolStats {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	stats := p.stats
+	stats.Transports = len(p.entries)
+	for _, e := range p.entries {
+		stats.References += e.refs
+	}
+	return stats
+}
+
+type transportSettings struct {
+	MaxIdleConns          int
+	IdleConnTimeout       int64
+	TLSHandshakeTimeout   int64
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	DialTimeout           int64
+	KeepAlive             int64
+	ResponseHeaderTimeout int64
+	ExpectContinueTimeout int64
+	DisableCompression    bool
+	DisableKeepAlives     bool
+	HTTP2                 bool
+	ReadBufferSize        int
+	WriteBufferSize       int
+	Proxy                 string
+	ProxyUsername         string
+	ProxyPassword         string
+	NoProxy               []string
+	UnixSocket            string
+	TLSCAFile             string
+	TLSCA                 string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSFilesDigest        string
+	TLSMinVersion         string
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+}
+
+func intOrDefault(v int, d int) int {
+	if v == 0 {
+		return d
+	}
+	return v
+}
+
+//tlsFilesDigest return digest of given tls files content,
+//so that rotated certificates with same path create new transport.
+//Unreadable files are skipped,error will be raised when creating transport.
+func tlsFilesDigest(files ...string) string {
+	h := sha256.New()
+	found := false
+	for _, file := range files {
+		if file == "" {
+			h.Write([]byte{0})
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			h.Write([]byte{0})
+			continue
+		}
+		found = true
+		h.Write([]byte{1})
+		h.Write(data)
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Client) transportKey() string {
+	noproxy := make([]string, 0, len(c.NoProxy))
+	for _, v := range c.NoProxy {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v != "" {
+			noproxy = append(noproxy, v)
+		}
+	}
+	sort.Strings(noproxy)
+	s := &transportSettings{
+		MaxIdleConns:          intOrDefault(c.MaxIdleConns, DefaultMaxIdleConns),
+		IdleConnTimeout:       int64(secondsOrDefault(c.IdleConnTimeoutInSecond, DefaultIdleConnTimeout)),
+		TLSHandshakeTimeout:   int64(secondsOrDefault(c.TLSHandshakeTimeoutInSecond, DefaultTLSHandshakeTimeout)),
+		MaxIdleConnsPerHost:   intOrDefault(c.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       intOrDefault(c.MaxConnsPerHost, DefaultMaxConnsPerHost),
+		DialTimeout:           int64(secondsOrDefault(c.DialTimeoutInSecond, DefaultDialTimeout)),
+		KeepAlive:             int64(secondsOrDefault(c.KeepAliveInSecond, DefaultKeepAlive)),
+		ResponseHeaderTimeout: int64(secondsOrDefault(c.ResponseHeaderTimeoutInSecond, DefaultResponseHeaderTimeout)),
+		ExpectContinueTimeout: int64(secondsOrDefault(c.ExpectContinueTimeoutInSecond, DefaultExpectContinueTimeout)),
+		DisableCompression:    c.DisableCompression,
+		DisableKeepAlives:     c.DisableKeepAlives,
+		HTTP2:                 c.attemptHTTP2(),
+		ReadBufferSize:        intOrDefault(c.ReadBufferSize, DefaultReadBufferSize),
+		WriteBufferSize:       intOrDefault(c.WriteBufferSize, DefaultWriteBufferSize),
+		Proxy:                 strings.TrimSpace(c.Proxy),
+		ProxyUsername:         c.ProxyUsername,
+		ProxyPassword:         c.ProxyPassword,
+		NoProxy:               noproxy,
+		UnixSocket:            c.UnixSocket,
+		TLSCAFile:             c.TLSCAFile,
+		TLSCA:                 c.TLSCA,
+		TLSCertFile:           c.TLSCertFile,
+		TLSKeyFile:            c.TLSKeyFile,
+		TLSFilesDigest:        tlsFilesDigest(c.TLSCAFile, c.TLSCertFile, c.TLSKeyFile),
+		TLSMinVersion:         c.TLSMinVersion,
+		TLSServerName:         c.TLSServerName,
+		TLSInsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+type clientTransport struct {
+	transport *http.Transport
+	pool      *TransportPool
+	//shared whether pool reference is held by transport owner instead of client.
+	shared bool
+}
+
+//shareTransports clone a new client which shares pool references with client.
+//Pooled transports used by cloned client are held by client,so cloned client needs not to be closed.
+func (c *Client) shareTransports() *Client {
+	cloned := c.Clone()
+	cloned.transportOwner = c
+	if c.transportOwner != nil {
+		cloned.transportOwner = c.transportOwner
+	}
+	return cloned
+}
+
+func (c *Client) setTransport(key string, ct *clientTransport) {
+	if c.transports == nil {
+		c.transports = map[string]*clientTransport{}
+	}
+	c.transports[key] = ct
+}
+
+//holdTransport acquire transport from given pool by given key,and hold the reference.
+//Reference will be acquired only once for every key.
+func (c *Client) holdTransport(pool *TransportPool, key string, create func() (*http.Transport, error)) (*http.Transport, error) {
+	c.poolLocker.Lock()
+	defer c.poolLocker.Unlock()
+	if ct, ok := c.transports[key]; ok && !ct.shared {
+		return ct.transport, nil
+	}
+	t, err := pool.Acquire(key, create)
+	if err != nil {
+		return nil, err
+	}
+	c.setTransport(key, &clientTransport{transport: t, pool: pool})
+	return t, nil
+}
+
+func (c *Client) acquireTransport(dial DialContextFunc) (*http.Transport, error) {
+	key := c.transportKey()
+	pool := DefaultTransportPool
+	if dial != nil || pool == nil {
+		key = "private:" + key
+		c.poolLocker.Lock()
+		defer c.poolLocker.Unlock()
+		if ct, ok := c.transports[key]; ok {
+			return ct.transport, nil
+		}
+		t, err := c.getTransport(dial)
+		if err != nil {
+			return nil, err
+		}
+		c.setTransport(key, &clientTransport{transport: t})
+		return t, nil
+	}
+	create := func() (*http.Transport, error) {
+		return c.getTransport(nil)
+	}
+	if c.transportOwner == nil {
+		return c.holdTransport(pool, key, create)
+	}
+	t, err := c.transportOwner.holdTransport(pool, key, create)
+	if err != nil {
+		return nil, err
+	}
+	c.poolLocker.Lock()
+	c.setTransport(key, &clientTransport{transport: t, pool: pool, shared: true})
+	c.poolLocker.Unlock()
+	return t, nil
+}
+
+//releaseTransports release transports held by client except given one.
+func (c *Client) releaseTransports(keep *http.Transport) {
+	c.poolLocker.Lock()
+	defer c.poolLocker.Unlock()
+	for key, ct := range c.transports {
+		if ct.transport == keep {
+			continue
+		}
+		delete(c.transports, key)
+		if ct.shared {
+			continue
+		}
+		if ct.pool == nil {
+			ct.transport.CloseIdleConnections()
+			continue
+		}
+		ct.pool.Release(key)
+	}
+}
+
+func (c *Client) heldTransports() []*http.Transport {
+	c.poolLocker.Lock()
+	defer c.poolLocker.Unlock()
+	result := make([]*http.Transport, 0, len(c.transports))
+	for _, ct := range c.transports {
+		result = append(result, ct.transport)
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package fetcher
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTransportPool(t *testing.T) {
+	p := NewTransportPool()
+	created := 0
+	create := func() (*http.Transport, error) {
+		created++
+		return &http.Transport{}, nil
+	}
+	t1, err := p.Acquire("a", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := p.Acquire("a", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t3, err := p.Acquire("b", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1 != t2 || t1 == t3 || created != 2 {
+		t.Fatal(t1, t2, t3, created)
+	}
+	stats := p.Stats()
+	if stats.Transports != 2 || stats.References != 3 || stats.Created != 2 || stats.Reused != 1 || stats.Released != 0 {
+		t.Fatal(stats)
+	}
+	p.Release("a")
+	p.Release("b")
+	p.Release("notexist")
+	stats = p.Stats()
+	if stats.Transports != 1 || stats.References != 1 || stats.Released != 1 {
+		t.Fatal(stats)
+	}
+	p.Release("a")
+	stats = p.Stats()
+	if stats.Transports != 0 || stats.References != 0 || stats.Released != 2 {
+		t.Fatal(stats)
+	}
+}
+
+func TestClientTransportPool(t *testing.T) {
+	pool := DefaultTransportPool
+	defer func() {
+		DefaultTransportPool = pool
+	}()
+	DefaultTransportPool = NewTransportPool()
+	server := httptest.NewServer(http.HandlerFunc(EchoAction))
+	defer server.Close()
+	s := &Server{}
+	s.URL = server.URL
+	for i := 0; i < 10; i++ {
+		p, err := s.CreatePreset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := p.Fetch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	stats := DefaultTransportPool.Stats()
+	if stats.Transports != 1 || stats.References != 1 {
+		t.Fatal(stats)
+	}
+	c1 := &Client{MaxIdleConns: DefaultMaxIdleConns, NoProxy: []string{"b", "A "}}
+	c2 := &Client{NoProxy: []string{"a", "b"}}
+	c3 := &Client{TimeoutInSecond: 5, MaxRedirects: 1}
+	for _, c := range []*Client{c1, c2, c3} {
+		err := c.SelfCheck()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats = DefaultTransportPool.Stats()
+	if stats.Transports != 2 || stats.References != 4 {
+		t.Fatal(stats)
+	}
+	c4 := &Client{}
+	c4.SetDialContext(func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	})
+	err := c4.SelfCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DefaultTransportPool.Stats().References != 4 {
+		t.Fatal(DefaultTransportPool.Stats())
+	}
+	c4.Close()
+	s.Close()
+	c1.Close()
+	c2.Close()
+	stats = DefaultTransportPool.Stats()
+	if stats.Transports != 1 || stats.References != 1 {
+		t.Fatal(stats)
+	}
+	err = c3.Reload(&Client{TimeoutInSecond: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DefaultTransportPool.Stats().References != 1 {
+		t.Fatal(DefaultTransportPool.Stats())
+	}
+	err = c3.Reload(&Client{DisableKeepAlives: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats = DefaultTransportPool.Stats()
+	if stats.Transports != 1 || stats.References != 1 {
+		t.Fatal(stats)
+	}
+	c3.Close()
+	if DefaultTransportPool.Stats().Transports != 0 {
+		t.Fatal(DefaultTransportPool.Stats())
+	}
+	DefaultTransportPool = nil
+	c5 := &Client{}
+	err = c5.SelfCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c5.Close()
+}
+
+func TestClientTransportPoolConcurrentReload(t *testing.T) {
+	pool := DefaultTransportPool
+	defer func() {
+		DefaultTransportPool = pool
+	}()
+	DefaultTransportPool = NewTransportPool()
+	c := &Client{}
+	for i := 0; i < 200; i++ {
+		wg := sync.WaitGroup{}
+		for k := 0; k < 4; k++ {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				if k == 3 {
+					c.Reset()
+					c.SelfCheck()
+					return
+				}
+				err := c.Reload(&Client{MaxIdleConns: i*3 + k + 1})
+				if err != nil {
+					t.Error(err)
+				}
+			}(k)
+		}
+		wg.Wait()
+		d, err := c.getDoer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		held := c.heldTransports()
+		stats := DefaultTransportPool.Stats()
+		if len(held) != 1 || held[0] != d.(*http.Client).Transport || stats.Transports != 1 || stats.References != 1 {
+			t.Fatal(i, stats, len(held))
+		}
+	}
+	c.Close()
+	stats := DefaultTransportPool.Stats()
+	if stats.Transports != 0 || stats.References != 0 {
+		t.Fatal(stats)
+	}
+}
+
+func TestServerCloneTransportPool(t *testing.T) {
+	pool := DefaultTransportPool
+	defer func() {
+		DefaultTransportPool = pool
+	}()
+	DefaultTransportPool = NewTransportPool()
+	server := httptest.NewServer(http.HandlerFunc(EchoAction))
+	defer server.Close()
+	s := &Server{}
+	s.URL = server.URL
+	for i := 0; i < 10; i++ {
+		for _, cloned := range []*Server{s.MustJoin("/users"), s.MergeMethod("POST"), s.Clone().MergeURL(server.URL + "/list")} {
+			_, err := MustPreset(cloned).FetchAndParse(Should200(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	stats := DefaultTransportPool.Stats()
+	if stats.Transports != 1 || stats.References != 1 {
+		t.Fatal(stats)
+	}
+	cloned := s.Clone()
+	cloned.Client.MaxIdleConns = 3
+	err := cloned.Client.SelfCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloned.Close()
+	stats = DefaultTransportPool.Stats()
+	if stats.Transports != 2 || stats.References != 2 {
+		t.Fatal(stats)
+	}
+	s.Close()
+	stats = DefaultTransportPool.Stats()
+	if stats.Transports != 0 || stats.References != 0 {
+		t.Fatal(stats)
+	}
+}
@@ -0,0 +1,500 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type uriTemplateOperator struct {
+	first   string
+	sep     string
+	named   bool
+	ifemp   string
+	reserve bool
+}
+
+var uriTemplateOperators = map[byte]*uriTemplateOperator{
+	'+': {first: "", sep: ",", reserve: true},
+	'#': {first: "#", sep: ",", reserve: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifemp: "="},
+	'&': {first: "&", sep: "&", named: true, ifemp: "="},
+}
+
+var uriTemplateSimpleOperator = &uriTemplateOperator{first: "", sep: ","}
+
+const uriTemplateReserved = ":/?#[]@!$&'()*+,;="
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func uriTemplateEscape(s string, reserve bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case reserve && strings.IndexByte(uriTemplateReserved, c) >= 0:
+			b.WriteByte(c)
+		case reserve && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i = i + 2
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+type uriTemplateVarSpec struct {
+	name    string
+	explode bool
+	prefix  int
+}
+
+func parseURITemplateVarSpec(spec string) (*uriTemplateVarSpec, error) {
+	v := &uriTemplateVarSpec{name: spec}
+	if strings.HasSuffix(spec, "*") {
+		v.explode = true
+		v.name = spec[:len(spec)-1]
+	} else if i := strings.IndexByte(spec, ':'); i >= 0 {
+		prefix, err := strconv.Atoi(spec[i+1:])
+		if err != nil || prefix <= 0 || prefix >= 10000 {
+			return nil, fmt.Errorf("fetcher:invalid uri template prefix %q", spec)
+		}
+		v.name = spec[:i]
+		v.prefix = prefix
+	}
+	if v.name == "" {
+		return nil, fmt.Errorf("fetcher:invalid uri template variable %q", spec)
+	}
+	for i := 0; i < len(v.name); i++ {
+		c := v.name[i]
+		if !(isUnreserved(c) && c != '-' && c != '~' || c == '%') {
+			return nil, fmt.Errorf("fetcher:invalid uri template variable %q", spec)
+		}
+	}
+	return v, nil
+}
+
+type uriTemplatePair struct {
+	key   string
+	value string
+}
+
+//uriTemplateValue convert given value to string,list or pairs.
+//Return false if value is undefined.
+func uriTemplateValue(v interface{}) (string, []string, []uriTemplatePair, bool, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", nil, nil, false, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return "", nil, nil, false, nil
+	}
+	switch {
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return "", nil, nil, false, nil
+		}
+		list := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s, ok, err := formatValue(rv.Index(i), "")
+			if err != nil {
+				return "", nil, nil, false, err
+			}
+			if ok {
+				list = append(list, s)
+			}
+		}
+		return "", list, nil, len(list) > 0, nil
+	case rv.Kind() == reflect.Map:
+		pairs := make([]uriTemplatePair, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, _, err := formatValue(iter.Key(), "")
+			if err != nil {
+				return "", nil, nil, false, err
+			}
+			value, ok, err := formatValue(iter.Value(), "")
+			if err != nil {
+				return "", nil, nil, false, err
+			}
+			if ok {
+				pairs = append(pairs, uriTemplatePair{key: k, value: value})
+			}
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i].key < pairs[j].key
+		})
+		return "", nil, pairs, len(pairs) > 0, nil
+	case rv.Kind() == reflect.Slice:
+		return string(rv.Bytes()), nil, nil, true, nil
+	}
+	if !rv.CanAddr() {
+		cp := reflect.New(rv.Type()).Elem()
+		cp.Set(rv)
+		rv = cp
+	}
+	s, ok, err := formatValue(rv, "")
+	return s, nil, nil, ok, err
+}
+
+func expandURITemplateExpression(b *strings.Builder, expr string, vars map[string]interface{}) error {
+	op := uriTemplateSimpleOperator
+	if expr != "" {
+		if o, ok := uriTemplateOperators[expr[0]]; ok {
+			op = o
+			expr = expr[1:]
+		} else if strings.IndexByte("=,!@|", expr[0]) >= 0 {
+			return fmt.Errorf("fetcher:unsupported uri template operator %q", expr[0])
+		}
+	}
+	first := true
+	for _, spec := range strings.Split(expr, ",") {
+		v, err := parseURITemplateVarSpec(spec)
+		if err != nil {
+			return err
+		}
+		s, list, pairs, ok, err := uriTemplateValue(vars[v.name])
+		if err != nil {
+			return fmt.Errorf("fetcher:uri template variable %q:%w", v.name, err)
+		}
+		if !ok {
+			continue
+		}
+		if v.prefix > 0 && (list != nil || pairs != nil) {
+			return fmt.Errorf("fetcher:prefix modifier can not be applied to composite variable %q", v.name)
+		}
+		if first {
+			b.WriteString(op.first)
+			first = false
+		} else {
+			b.WriteString(op.sep)
+		}
+		switch {
+		case list != nil:
+			writeURITemplateList(b, op, v, list)
+		case pairs != nil:
+			writeURITemplatePairs(b, op, v, pairs)
+		default:
+			if v.prefix > 0 && utf8.RuneCountInString(s) > v.prefix {
+				s = string([]rune(s)[:v.prefix])
+			}
+			writeURITemplateNamed(b, op, v.name, s)
+		}
+	}
+	return nil
+}
+
+func writeURITemplateNamed(b *strings.Builder, op *uriTemplateOperator, name string, value string) {
+	if op.named {
+		b.WriteString(name)
+		if value == "" {
+			b.WriteString(op.ifemp)
+			return
+		}
+		b.WriteByte('=')
+	}
+	b.WriteString(uriTemplateEscape(value, op.reserve))
+}
+
+func writeURITemplateList(b *strings.Builder, op *uriTemplateOperator, v *uriTemplateVarSpec, list []string) {
+	if v.explode {
+		for k, item := range list {
+			if k > 0 {
+				b.WriteString(op.sep)
+			}
+			writeURITemplateNamed(b, op, v.name, item)
+		}
+		return
+	}
+	if op.named {
+		b.WriteString(v.name)
+		b.WriteByte('=')
+	}
+	for k, item := range list {
+		if k > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(uriTemplateEscape(item, op.reserve))
+	}
+}
+
+func writeURITemplatePairs(b *strings.Builder, op *uriTemplateOperator, v *uriTemplateVarSpec, pairs []uriTemplatePair) {
+	if v.explode {
+		for k, p := range pairs {
+			if k > 0 {
+				b.WriteString(op.sep)
+			}
+			b.WriteString(uriTemplateEscape(p.key, op.reserve))
+			if op.named && p.value == "" {
+				b.WriteString(op.ifemp)
+				continue
+			}
+			b.WriteByte('=')
+			b.WriteString(uriTemplateEscape(p.value, op.reserve))
+		}
+		return
+	}
+	if op.named {
+		b.WriteString(v.name)
+		b.WriteByte('=')
+	}
+	for k, p := range pairs {
+		if k > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(uriTemplateEscape(p.key, op.reserve))
+		b.WriteByte(',')
+		b.WriteString(uriTemplateEscape(p.value, op.reserve))
+	}
+}
+
+//ExpandURITemplate expand given RFC 6570 uri template with given variables.
+//All four levels of RFC 6570 are supported.
+//Variable could be string,bool,numbers,time.Time,encoding.TextMarshaler,fmt.Stringer,
+//slices of them as list or maps of them as associative array.
+//Nil value,empty slice and empty map are treated as undefined.
+//Return expanded uri and any error if raised.
+func ExpandURITemplate(tmpl string, vars map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for len(tmpl) > 0 {
+		start := strings.IndexAny(tmpl, "{}")
+		if start < 0 {
+			b.WriteString(uriTemplateEscape(tmpl, true))
+			break
+		}
+		if tmpl[start] == '}' {
+			return "", fmt.Errorf("fetcher:unexpected '}' in uri template")
+		}
+		b.WriteString(uriTemplateEscape(tmpl[:start], true))
+		end := strings.IndexAny(tmpl[start+1:], "{}")
+		if end < 0 || tmpl[start+1+end] == '{' {
+			return "", fmt.Errorf("fetcher:unclosed expression in uri template")
+		}
+		err := expandURITemplateExpression(&b, tmpl[start+1:start+1+end], vars)
+		if err != nil {
+			return "", err
+		}
+		tmpl = tmpl[start+end+2:]
+	}
+	return b.String(), nil
+}
+
+//URITemplate command which modify fetcher url by given RFC 6570 uri template and variables.
+//Absolute uri replaces fetcher url.
+//Otherwise expanded path will be appended to fetcher path,and expanded query will be added to fetcher query,
+//so that template composes with presets as PathJoin does.
+//Both Path and RawPath will be set,escaped "/" in variables will be kept.
+//If fetcher url template is set,it will be expanded with fetcher template vars first,
+//and expanded uri will be applied on it.
+func URITemplate(tmpl string, vars map[string]interface{}) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		expanded, err := ExpandURITemplate(tmpl, vars)
+		if err != nil {
+			return err
+		}
+		ref, err := url.Parse(expanded)
+		if err != nil {
+			return err
+		}
+		if ref.Scheme != "" || ref.Host != "" {
+			f.URL = f.URL.ResolveReference(ref)
+			f.URLTemplate = ""
+			return nil
+		}
+		base := &url.URL{}
+		*base = *f.URL
+		if f.URLTemplate != "" {
+			base, err = templateURL(f.URLTemplate, f.TemplateVars, f.URL)
+			if err != nil {
+				return err
+			}
+		}
+		err = mergeURL(base, ref)
+		if err != nil {
+			return err
+		}
+		f.URL = base
+		f.URLTemplate = ""
+		return nil
+	})
+}
+
+//TemplateURL command which modify fetcher url template to given RFC 6570 uri template.
+//Template will be expanded with fetcher template vars when request created.
+//Fetcher url will be reset,and then applied on expanded url.
+func TemplateURL(tmpl string) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		f.URLTemplate = tmpl
+		f.URL = &url.URL{}
+		return nil
+	})
+}
+
+//TemplateVar command which set fetcher template var by given name and value.
+func TemplateVar(name string, value interface{}) Command {
+	return TemplateVars(map[string]interface{}{name: value})
+}
+
+//TemplateVars command which merge fetcher template vars with given vars.
+func TemplateVars(vars map[string]interface{}) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		f.TemplateVars = mergeTemplateVars(f.TemplateVars, vars)
+		return nil
+	})
+}
+
+func mergeTemplateVars(src map[string]interface{}, vars map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(src)+len(vars))
+	for k, v := range src {
+		merged[k] = v
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return merged
+}
+
+//uriTemplateVarName return variable name if given placeholder is simple expression like "{name}".
+//Return empty string if not.
+func uriTemplateVarName(placeholder string) string {
+	if len(placeholder) < 3 || placeholder[0] != '{' || placeholder[len(placeholder)-1] != '}' {
+		return ""
+	}
+	name := placeholder[1 : len(placeholder)-1]
+	v, err := parseURITemplateVarSpec(name)
+	if err != nil || v.name != name {
+		return ""
+	}
+	return name
+}
+
+var uriTemplateLiteralEscaper = strings.NewReplacer("%", "%25", "{", "%7B", "}", "%7D")
+
+//escapeURITemplateLiteral escape given path so that it can be used as literal in uri template.
+func escapeURITemplateLiteral(p string) string {
+	return uriTemplateLiteralEscaper.Replace(p)
+}
+
+//prefixURITemplatePath insert given prefix before path of given uri template.
+func prefixURITemplatePath(tmpl string, prefix string) string {
+	start := 0
+	if i := strings.Index(tmpl, "://"); i >= 0 && !strings.ContainsAny(tmpl[:i], "/?#{") {
+		start = i + 3
+		for start < len(tmpl) {
+			c := tmpl[start]
+			if c == '/' || c == '?' || c == '#' {
+				break
+			}
+			if c == '{' {
+				if start+1 < len(tmpl) && strings.IndexByte("/?&#", tmpl[start+1]) >= 0 {
+					break
+				}
+				end := strings.IndexByte(tmpl[start:], '}')
+				if end < 0 {
+					break
+				}
+				start = start + end + 1
+				continue
+			}
+			start++
+		}
+	}
+	return tmpl[:start] + prefix + tmpl[start:]
+}
+
+//IsURITemplate check if given url contains uri template expressions.
+func IsURITemplate(u string) bool {
+	return strings.Contains(u, "{")
+}
+
+//joinURITemplate join given url template and path.
+func joinURITemplate(tmpl string, urlpath string) string {
+	end := len(tmpl)
+	for _, sep := range []string{"{?", "{&", "{#", "?", "#"} {
+		if i := strings.Index(tmpl, sep); i >= 0 && i < end {
+			end = i
+		}
+	}
+	if urlpath == "" {
+		return tmpl
+	}
+	return strings.TrimSuffix(tmpl[:end], "/") + "/" + strings.TrimPrefix(urlpath, "/") + tmpl[end:]
+}
+
+//templateURL expand url template and apply given url on it.
+//Path of given url will be appended to expanded path,and query will be added to expanded query.
+func templateURL(tmpl string, vars map[string]interface{}, u *url.URL) (*url.URL, error) {
+	expanded, err := ExpandURITemplate(tmpl, vars)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(expanded)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeURL(base, u)
+	if err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+//mergeURL apply given url on base url.
+//Scheme,host,user and fragment will be replaced if not empty,
+//path will be appended to base path and query will be added to base query.
+func mergeURL(base *url.URL, u *url.URL) error {
+	if u.Scheme != "" {
+		base.Scheme = u.Scheme
+	}
+	if u.Host != "" {
+		base.Host = u.Host
+	}
+	if u.User != nil {
+		base.User = u.User
+	}
+	if p := u.EscapedPath(); p != "" {
+		basepath := base.EscapedPath()
+		switch {
+		case strings.HasPrefix(p, "/") && strings.HasSuffix(basepath, "/"):
+			p = p[1:]
+		case !strings.HasPrefix(p, "/") && !strings.HasSuffix(basepath, "/"):
+			p = "/" + p
+		}
+		joined, err := url.Parse(basepath + p)
+		if err != nil {
+			return err
+		}
+		base.Path = joined.Path
+		base.RawPath = joined.RawPath
+	}
+	if u.RawQuery != "" {
+		q := base.Query()
+		for k, v := range u.Query() {
+			q[k] = append(q[k], v...)
+		}
+		base.RawQuery = q.Encode()
+	}
+	if u.Fragment != "" {
+		base.Fragment = u.Fragment
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+var testURITemplateVars = map[string]interface{}{
+	"count":      []string{"one", "two", "three"},
+	"dom":        []string{"example", "com"},
+	"dub":        "me/too",
+	"hello":      "Hello World!",
+	"half":       "50%",
+	"var":        "value",
+	"who":        "fred",
+	"base":       "http://example.com/home/",
+	"path":       "/foo/bar",
+	"list":       []string{"red", "green", "blue"},
+	"keys":       map[string]string{"semi": ";", "dot": ".", "comma": ","},
+	"v":          6,
+	"x":          "1024",
+	"y":          768,
+	"empty":      "",
+	"empty_keys": map[string]string{},
+	"undef":      nil,
+	"unicode":    "中文字",
+}
+
+func TestExpandURITemplate(t *testing.T) {
+	var cases = [][2]string{
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{half}", "50%25"},
+		{"O{empty}X", "OX"},
+		{"O{undef}X", "OX"},
+		{"{x,y}", "1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"?{x,empty}", "?1024,"},
+		{"?{x,undef}", "?1024"},
+		{"?{undef,y}", "?768"},
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"{unicode:2}", "%E4%B8%AD%E6%96%87"},
+		{"{count}", "one,two,three"},
+		{"{count*}", "one,two,three"},
+		{"{list}", "red,green,blue"},
+		{"{list*}", "red,green,blue"},
+		{"{keys}", "comma,%2C,dot,.,semi,%3B"},
+		{"{keys*}", "comma=%2C,dot=.,semi=%3B"},
+		{"{+var}", "value"},
+		{"{+hello}", "Hello%20World!"},
+		{"{+half}", "50%25"},
+		{"{base}index", "http%3A%2F%2Fexample.com%2Fhome%2Findex"},
+		{"{+base}index", "http://example.com/home/index"},
+		{"O{+empty}X", "OX"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"here?ref={+path}", "here?ref=/foo/bar"},
+		{"up{+path}{var}/here", "up/foo/barvalue/here"},
+		{"{+x,hello,y}", "1024,Hello%20World!,768"},
+		{"{+path,x}/here", "/foo/bar,1024/here"},
+		{"{+path:6}/here", "/foo/b/here"},
+		{"{+list}", "red,green,blue"},
+		{"{+keys*}", "comma=,,dot=.,semi=;"},
+		{"{#var}", "#value"},
+		{"{#hello}", "#Hello%20World!"},
+		{"{#half}", "#50%25"},
+		{"foo{#empty}", "foo#"},
+		{"foo{#undef}", "foo"},
+		{"{#x,hello,y}", "#1024,Hello%20World!,768"},
+		{"{#path,x}/here", "#/foo/bar,1024/here"},
+		{"{#path:6}/here", "#/foo/b/here"},
+		{"{#list*}", "#red,green,blue"},
+		{"{#keys}", "#comma,,,dot,.,semi,;"},
+		{"{.who}", ".fred"},
+		{"{.who,who}", ".fred.fred"},
+		{"{.half,who}", ".50%25.fred"},
+		{"www{.dom*}", "www.example.com"},
+		{"X{.var}", "X.value"},
+		{"X{.empty}", "X."},
+		{"X{.undef}", "X"},
+		{"X{.var:3}", "X.val"},
+		{"X{.list}", "X.red,green,blue"},
+		{"X{.list*}", "X.red.green.blue"},
+		{"X{.keys}", "X.comma,%2C,dot,.,semi,%3B"},
+		{"X{.keys*}", "X.comma=%2C.dot=..semi=%3B"},
+		{"X{.empty_keys}", "X"},
+		{"{/who}", "/fred"},
+		{"{/who,who}", "/fred/fred"},
+		{"{/half,who}", "/50%25/fred"},
+		{"{/who,dub}", "/fred/me%2Ftoo"},
+		{"{/var}", "/value"},
+		{"{/var,empty}", "/value/"},
+		{"{/var,undef}", "/value"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{/var:1,var}", "/v/value"},
+		{"{/list}", "/red,green,blue"},
+		{"{/list*}", "/red/green/blue"},
+		{"{/list*,path:4}", "/red/green/blue/%2Ffoo"},
+		{"{/keys}", "/comma,%2C,dot,.,semi,%3B"},
+		{"{/keys*}", "/comma=%2C/dot=./semi=%3B"},
+		{"{;who}", ";who=fred"},
+		{"{;half}", ";half=50%25"},
+		{"{;empty}", ";empty"},
+		{"{;v,empty,who}", ";v=6;empty;who=fred"},
+		{"{;v,bar,who}", ";v=6;who=fred"},
+		{"{;x,y}", ";x=1024;y=768"},
+		{"{;x,y,empty}", ";x=1024;y=768;empty"},
+		{"{;x,y,undef}", ";x=1024;y=768"},
+		{"{;hello:5}", ";hello=Hello"},
+		{"{;list}", ";list=red,green,blue"},
+		{"{;list*}", ";list=red;list=green;list=blue"},
+		{"{;keys}", ";keys=comma,%2C,dot,.,semi,%3B"},
+		{"{;keys*}", ";comma=%2C;dot=.;semi=%3B"},
+		{"{?who}", "?who=fred"},
+		{"{?half}", "?half=50%25"},
+		{"{?x,y}", "?x=1024&y=768"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"{?x,y,undef}", "?x=1024&y=768"},
+		{"{?var:3}", "?var=val"},
+		{"{?list}", "?list=red,green,blue"},
+		{"{?list*}", "?list=red&list=green&list=blue"},
+		{"{?keys}", "?keys=comma,%2C,dot,.,semi,%3B"},
+		{"{?keys*}", "?comma=%2C&dot=.&semi=%3B"},
+		{"{&who}", "&who=fred"},
+		{"{&half}", "&half=50%25"},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{&x,y,empty}", "&x=1024&y=768&empty="},
+		{"{&var:3}", "&var=val"},
+		{"{&list}", "&list=red,green,blue"},
+		{"{&list*}", "&list=red&list=green&list=blue"},
+		{"{&keys}", "&keys=comma,%2C,dot,.,semi,%3B"},
+		{"{&keys*}", "&comma=%2C&dot=.&semi=%3B"},
+		{"/literal space/%2F", "/literal%20space/%2F"},
+	}
+	for _, c := range cases {
+		result, err := ExpandURITemplate(c[0], testURITemplateVars)
+		if err != nil {
+			t.Fatal(c[0], err)
+		}
+		if result != c[1] {
+			t.Fatal(c[0], result)
+		}
+	}
+	var errcases = []string{
+		"{var",
+		"var}",
+		"{va{r}",
+		"{}",
+		"{=var}",
+		"{var:0}",
+		"{var:abc}",
+		"{list:3}",
+		"{va-r}",
+	}
+	for _, c := range errcases {
+		_, err := ExpandURITemplate(c, testURITemplateVars)
+		if err == nil {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestURITemplate(t *testing.T) {
+	f := New()
+	vars := map[string]interface{}{
+		"owner":  "herb-go",
+		"repo":   "a/b",
+		"state":  "open",
+		"labels": map[string]string{"bug": "yes"},
+	}
+	err := Exec(f, URL("https://api.example.com/v3/?old=1"), URITemplate("/repos/{owner}/{repo}/issues{?state,labels*}", vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.Path != "/v3/repos/herb-go/a/b/issues" || f.URL.RawPath != "/v3/repos/herb-go/a%2Fb/issues" {
+		t.Fatal(f.URL.Path, f.URL.RawPath)
+	}
+	if f.URL.String() != "https://api.example.com/v3/repos/herb-go/a%2Fb/issues?bug=yes&old=1&state=open" {
+		t.Fatal(f.URL.String())
+	}
+	err = URITemplate("http://other.example.com{/repo}", vars).Exec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.String() != "http://other.example.com/a%2Fb" {
+		t.Fatal(f.URL.String())
+	}
+	err = URITemplate("{var", vars).Exec(f)
+	if err == nil {
+		t.Fatal(err)
+	}
+	base, err := url.Parse("http://h/api/v3?key=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f = New()
+	err = Exec(f, ParsedURL(base), URITemplate("/repos/{repo}/issues{?state}", vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.String() != "http://h/api/v3/repos/a%2Fb/issues?key=1&state=open" {
+		t.Fatal(f.URL.String())
+	}
+	if base.String() != "http://h/api/v3?key=1" {
+		t.Fatal(base.String())
+	}
+	f = New()
+	err = Exec(f, ParsedURL(base), URITemplate("{+path}", map[string]interface{}{"path": "list#top"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL.String() != "http://h/api/v3/list?key=1#top" {
+		t.Fatal(f.URL.String())
+	}
+}
+
+func TestTemplateURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer server.Close()
+	s := &ServerInfo{URL: server.URL + "/api/{version}{?token}"}
+	s = s.MustJoin("/users")
+	if s.URL != server.URL+"/api/{version}/users{?token}" {
+		t.Fatal(s.URL)
+	}
+	preset := MustPreset(s).With(PathSuffix("/list"), SetQuery("page", "2"), TemplateVars(map[string]interface{}{"version": "v1", "token": "secret"}))
+	var result string
+	_, err := FetchAndParse(preset.With(TemplateVar("version", "v 2")), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "/api/v%202/users/list?page=2&token=secret" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(preset, Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "/api/v1/users/list?page=2&token=secret" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(preset.With(URL(server.URL+"/plain")), Should200(AsString(&result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "/plain" {
+		t.Fatal(result)
+	}
+	_, err = FetchAndParse(preset.With(TemplateURL("{")), AsUselessBody)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemplateURLCommands(t *testing.T) {
+	s := &ServerInfo{URL: "https://api.example.com/{version}/users/{id}{?key}"}
+	rawURL := func(cmds ...Command) string {
+		f := New()
+		err := Exec(f, append([]Command{MustPreset(s), TemplateVars(map[string]interface{}{"version": "v1", "key": "k"})}, cmds...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _, err := f.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req.URL.String()
+	}
+	var cases = []struct {
+		cmds     []Command
+		expected string
+	}{
+		{[]Command{Replace("{id}", "a b")}, "https://api.example.com/v1/users/a%20b?key=k"},
+		{[]Command{PathPrefix("/pre"), Replace("{id}", "1")}, "https://api.example.com/pre/v1/users/1?key=k"},
+		{[]Command{Replace("users", "members"), Replace("{id}", "1")}, "https://api.example.com/v1/members/1?key=k"},
+		{[]Command{Bind(struct {
+			ID   int    `path:"id"`
+			Page string `query:"page"`
+		}{ID: 3, Page: "2"})}, "https://api.example.com/v1/users/3?key=k&page=2"},
+		{[]Command{Replace("{id}", "1"), AddQuery("key", "k2")}, "https://api.example.com/v1/users/1?key=k&key=k2"},
+		{[]Command{Replace("{id}", "1"), URITemplate("/x/{id}{?page}", map[string]interface{}{"id": 3, "page": 2})}, "https://api.example.com/v1/users/1/x/3?key=k&page=2"},
+		{[]Command{TemplateVar("version", "v2"), URITemplate("x/{id}", map[string]interface{}{"id": 3})}, "https://api.example.com/v2/users/x/3?key=k"},
+	}
+	for _, c := range cases {
+		result := rawURL(c.cmds...)
+		if result != c.expected {
+			t.Fatal(result, c.expected)
+		}
+	}
+	var prefixcases = map[string]string{
+		"https://{host}/a{?q}":      "https://{host}/pre/a{?q}",
+		"http://example.com{/path}": "http://example.com/pre{/path}",
+		"http://example.com":        "http://example.com/pre",
+		"/a/{b}":                    "/pre/a/{b}",
+	}
+	for tmpl, expected := range prefixcases {
+		result := prefixURITemplatePath(tmpl, "/pre")
+		if result != expected {
+			t.Fatal(tmpl, result)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+)
+
+//XMLContentType content type of xml body.
+var XMLContentType = "application/xml; charset=utf-8"
+
+//XMLOptions options used to encode xml body.
+type XMLOptions struct {
+	//Root root element name.
+	//Name from XMLName field or type name will be used if empty.
+	Root string
+	//Namespace root element namespace,which will be written as xmlns attribute.
+	Namespace string
+	//OmitDeclaration whether xml declaration header should be omitted.
+	OmitDeclaration bool
+	//Indent indent string.
+	//Output will not be indented if empty.
+	Indent string
+}
+
+//XMLBody command which modify fetcher body to given value as xml.
+//Xml declaration header will be written.
+//Content-Type header will be set to XMLContentType.
+//Fetcher body will set to nil if v is nil.
+func XMLBody(v interface{}) Command {
+	return XMLBodyWithOptions(v, nil)
+}
+
+//XMLBodyWithOptions command which modify fetcher body to given value as xml with given options.
+//Content-Type header will be set to XMLContentType.
+//Body can be replayed.
+//Fetcher body will set to nil if v is nil.
+func XMLBodyWithOptions(v interface{}, opts *XMLOptions) Command {
+	return CommandFunc(func(f *Fetcher) error {
+		if v == nil {
+			f.Body = nil
+			f.BodySource = nil
+			return nil
+		}
+		bs, err := MarshalXML(v, opts)
+		if err != nil {
+			return err
+		}
+		f.Body = nil
+		f.BodySource = BytesSource(bs)
+		f.Header.Set("Content-Type", XMLContentType)
+		return nil
+	})
+}
+
+//MarshalXML marshal given value to xml with given options.
+//Default options will be used if opts is nil.
+//Return xml data and any error if raised.
+func MarshalXML(v interface{}, opts *XMLOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &XMLOptions{}
+	}
+	buf := bytes.NewBuffer(nil)
+	if !opts.OmitDeclaration {
+		buf.WriteString(xml.Header)
+	}
+	enc := xml.NewEncoder(buf)
+	if opts.Indent != "" {
+		enc.Indent("", opts.Indent)
+	}
+	var err error
+	if opts.Root == "" && opts.Namespace == "" {
+		err = enc.Encode(v)
+	} else {
+		root := opts.Root
+		if root == "" {
+			root = xmlRootName(v)
+		}
+		start := xml.StartElement{Name: xml.Name{Local: root}}
+		if opts.Namespace != "" {
+			start.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: opts.Namespace}}
+		}
+		err = enc.EncodeElement(v, start)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xmlRootName(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return ""
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			break
+		}
+		rv = rv.Elem()
+	}
+	rt := rv.Type()
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() == reflect.Struct {
+		if field, ok := rt.FieldByName("XMLName"); ok && field.Type == reflect.TypeOf(xml.Name{}) {
+			if rv.Kind() == reflect.Struct {
+				if name := rv.FieldByIndex(field.Index).Interface().(xml.Name); name.Local != "" {
+					return name.Local
+				}
+			}
+			tag := field.Tag.Get("xml")
+			if tag != "" && tag != "-" {
+				name, _ := parseTag(tag)
+				if i := strings.LastIndex(name, " "); i >= 0 {
+					name = name[i+1:]
+				}
+				if name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return rt.Name()
+}
+
+//AsXML create parser which parse given value from response in xml format.
+func AsXML(v interface{}) Parser {
+	return ParserFunc(func(resp *Response) error {
+		bs, err := resp.BodyContent()
+		if err != nil {
+			return err
+		}
+		return xml.Unmarshal(bs, v)
+	})
+}
@@ -0,0 +1,111 @@
+package fetcher
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testXMLOrder struct {
+	XMLName xml.Name `xml:"order"`
+	ID      string   `xml:"id,attr"`
+	Amount  int      `xml:"amount"`
+}
+
+type testXMLItem struct {
+	Name string `xml:"name"`
+}
+
+type testXMLResult struct {
+	XMLName xml.Name `xml:"urn:payment result"`
+	Code    string   `xml:"urn:payment code"`
+}
+
+func TestMarshalXML(t *testing.T) {
+	order := &testXMLOrder{ID: "1", Amount: 100}
+	bs, err := MarshalXML(order, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != xml.Header+`<order id="1"><amount>100</amount></order>` {
+		t.Fatal(string(bs))
+	}
+	bs, err = MarshalXML(order, &XMLOptions{Root: "request", Namespace: "urn:payment", OmitDeclaration: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != `<request xmlns="urn:payment" id="1"><amount>100</amount></request>` {
+		t.Fatal(string(bs))
+	}
+	bs, err = MarshalXML(order, &XMLOptions{Namespace: "urn:payment", OmitDeclaration: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != `<order xmlns="urn:payment" id="1"><amount>100</amount></order>` {
+		t.Fatal(string(bs))
+	}
+	bs, err = MarshalXML(testXMLItem{Name: "item"}, &XMLOptions{Namespace: "urn:item", OmitDeclaration: true, Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "<testXMLItem xmlns=\"urn:item\">\n  <name>item</name>\n</testXMLItem>" {
+		t.Fatal(string(bs))
+	}
+	_, err = MarshalXML(make(chan int), nil)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXMLBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order := &testXMLOrder{}
+		bs, _ := ioutil.ReadAll(r.Body)
+		err := xml.Unmarshal(bs, order)
+		if err != nil || r.Header.Get("Content-Type") != XMLContentType {
+			w.WriteHeader(400)
+			return
+		}
+		w.Write([]byte(xml.Header + `<result xmlns="urn:payment"><code>` + order.ID + `-ok</code></result>`))
+	}))
+	defer server.Close()
+	result := &testXMLResult{}
+	_, err := FetchAndParse(NewPreset().With(URL(server.URL), Post, XMLBody(&testXMLOrder{ID: "1"})), Should200(AsXML(result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != "1-ok" {
+		t.Fatal(result)
+	}
+	result = &testXMLResult{}
+	_, err = FetchAndParse(NewPreset().With(URL(server.URL), Post, XMLBodyWithOptions(&testXMLOrder{ID: "2"}, &XMLOptions{Root: "order", Namespace: "urn:order"})), Should200(AsXML(result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != "2-ok" {
+		t.Fatal(result)
+	}
+	f := New()
+	err = XMLBody(nil).Exec(f)
+	if err != nil || f.Body != nil {
+		t.Fatal(f, err)
+	}
+	err = XMLBody(make(chan int)).Exec(f)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsXML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<result xmlns="urn:other"><code>1</code></result>`))
+	}))
+	defer server.Close()
+	result := &testXMLResult{}
+	_, err := FetchAndParse(NewPreset().With(URL(server.URL)), AsXML(result))
+	if err == nil {
+		t.Fatal(err)
+	}
+}